@@ -0,0 +1,138 @@
+package thin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Advisory is a security advisory for a gem in the ruby-advisory-db format.
+type Advisory struct {
+	Gem                string   `yaml:"gem"`
+	CVE                string   `yaml:"cve"`
+	GHSA               string   `yaml:"ghsa"`
+	Title              string   `yaml:"title"`
+	URL                string   `yaml:"url"`
+	CVSSv2             float64  `yaml:"cvss_v2"`
+	CVSSv3             float64  `yaml:"cvss_v3"`
+	Criticality        string   `yaml:"criticality"`
+	PatchedVersions    []string `yaml:"patched_versions"`
+	UnaffectedVersions []string `yaml:"unaffected_versions"`
+
+	// ID identifies the advisory: its CVE id, its GHSA id, or the name of its
+	// file when it has neither.
+	ID string `yaml:"-"`
+}
+
+// advisorySeverities are the severities of advisories, from lowest to highest.
+var advisorySeverities = []string{"none", "low", "medium", "high", "critical"}
+
+// Severity returns the severity of the advisory, taken from its criticality
+// or else derived from its CVSS score. It returns "unknown" if the advisory
+// declares neither.
+func (a Advisory) Severity() string {
+	if criticality := strings.ToLower(a.Criticality); slices.Contains(advisorySeverities, criticality) {
+		return criticality
+	}
+
+	score := a.CVSSv3
+	if score == 0 {
+		score = a.CVSSv2
+	}
+
+	switch {
+	case score == 0:
+		return "unknown"
+	case score < 4:
+		return "low"
+	case score < 7:
+		return "medium"
+	case score < 9:
+		return "high"
+	default:
+		return "critical"
+	}
+}
+
+// Affects reports whether the given gem version is affected by the advisory,
+// i.e. it is neither a patched nor an unaffected version.
+func (a Advisory) Affects(version string) (bool, error) {
+	for _, requirements := range append(slices.Clone(a.PatchedVersions), a.UnaffectedVersions...) {
+		satisfied, err := GemRequirementSatisfied(version, requirements)
+		if err != nil {
+			return false, fmt.Errorf("failed to check advisory %s: %w", a.ID, err)
+		}
+
+		if satisfied {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// AdvisoryFinding is an advisory that affects a locked gem version.
+type AdvisoryFinding struct {
+	Advisory Advisory
+	Version  string
+}
+
+// CheckAdvisories checks the locked versions of the given gems against the
+// advisory database at dbPath, which follows the ruby-advisory-db layout
+// (gems/<gem>/<advisory>.yml). It returns the advisories that affect them.
+func CheckAdvisories(dbPath string, lockfile GemfileLock, gems []string) ([]AdvisoryFinding, error) {
+	info, err := os.Stat(filepath.Join(dbPath, "gems"))
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("failed to load advisory database: %s does not contain a gems directory", dbPath)
+	}
+
+	var findings []AdvisoryFinding
+	for _, gem := range gems {
+		version, ok := lockfile.Gems[gem]
+		if !ok {
+			continue
+		}
+
+		paths, err := filepath.Glob(filepath.Join(dbPath, "gems", gem, "*.yml"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read advisory: %w", err)
+			}
+
+			var advisory Advisory
+			err = yaml.Unmarshal(content, &advisory)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse advisory %s: %w", path, err)
+			}
+
+			switch {
+			case advisory.CVE != "":
+				advisory.ID = "CVE-" + strings.TrimPrefix(advisory.CVE, "CVE-")
+			case advisory.GHSA != "":
+				advisory.ID = "GHSA-" + strings.TrimPrefix(advisory.GHSA, "GHSA-")
+			default:
+				advisory.ID = strings.TrimSuffix(filepath.Base(path), ".yml")
+			}
+
+			affected, err := advisory.Affects(version)
+			if err != nil {
+				return nil, err
+			}
+
+			if affected {
+				findings = append(findings, AdvisoryFinding{Advisory: advisory, Version: version})
+			}
+		}
+	}
+
+	return findings, nil
+}
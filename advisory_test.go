@@ -0,0 +1,146 @@
+package thin_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testAdvisory(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		dbPath string
+	)
+
+	it.Before(func() {
+		var err error
+		dbPath, err = os.MkdirTemp("", "ruby-advisory-db")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(dbPath, "gems", "eventmachine"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dbPath, "gems", "eventmachine", "OSVDB-0001.yml"), []byte(`---
+gem: eventmachine
+title: Some eventmachine advisory
+criticality: Low
+unaffected_versions:
+  - "< 1.0.0"
+patched_versions:
+  - ">= 1.2.7"
+`), 0600)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(dbPath, "gems", "thin"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dbPath, "gems", "thin", "GHSA-aaaa-bbbb-cccc.yml"), []byte(`---
+gem: thin
+ghsa: aaaa-bbbb-cccc
+title: Some thin advisory
+cvss_v2: 9.3
+patched_versions:
+  - "~> 1.7.2"
+  - ">= 1.8.2"
+`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(dbPath)).To(Succeed())
+	})
+
+	context("CheckAdvisories", func() {
+		it("returns the advisories that affect the locked gems", func() {
+			findings, err := thin.CheckAdvisories(dbPath, thin.GemfileLock{
+				Gems: map[string]string{
+					"eventmachine": "1.0.3",
+					"thin":         "1.8.1",
+				},
+			}, []string{"thin", "eventmachine"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(findings).To(HaveLen(2))
+			Expect(findings[0].Advisory.ID).To(Equal("GHSA-aaaa-bbbb-cccc"))
+			Expect(findings[0].Advisory.Severity()).To(Equal("critical"))
+			Expect(findings[0].Version).To(Equal("1.8.1"))
+			Expect(findings[1].Advisory.ID).To(Equal("OSVDB-0001"))
+			Expect(findings[1].Advisory.Severity()).To(Equal("low"))
+		})
+
+		it("skips patched and unaffected versions", func() {
+			findings, err := thin.CheckAdvisories(dbPath, thin.GemfileLock{
+				Gems: map[string]string{
+					"eventmachine": "0.12.10",
+					"thin":         "1.7.2",
+				},
+			}, []string{"thin", "eventmachine"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findings).To(BeEmpty())
+
+			findings, err = thin.CheckAdvisories(dbPath, thin.GemfileLock{
+				Gems: map[string]string{
+					"eventmachine": "1.2.7-x86_64-linux",
+					"thin":         "1.8.2",
+				},
+			}, []string{"thin", "eventmachine"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findings).To(BeEmpty())
+		})
+
+		context("failure cases", func() {
+			context("when the database has no gems directory", func() {
+				it.Before(func() {
+					Expect(os.RemoveAll(filepath.Join(dbPath, "gems"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := thin.CheckAdvisories(dbPath, thin.GemfileLock{}, []string{"thin"})
+					Expect(err).To(MatchError(ContainSubstring("failed to load advisory database:")))
+				})
+			})
+
+			context("when an advisory cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(dbPath, "gems", "thin", "GHSA-aaaa-bbbb-cccc.yml"), []byte("%%%"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := thin.CheckAdvisories(dbPath, thin.GemfileLock{Gems: map[string]string{"thin": "1.8.1"}}, []string{"thin"})
+					Expect(err).To(MatchError(ContainSubstring("failed to parse advisory")))
+				})
+			})
+		})
+	})
+
+	context("Affects", func() {
+		it("compares gem versions the way RubyGems does", func() {
+			advisory := thin.Advisory{PatchedVersions: []string{"~> 2.0.9, >= 2.0.9.1", ">= 2.2.3.1"}}
+
+			for version, affected := range map[string]bool{
+				"2.0.8":        true,
+				"2.0.9":        true,
+				"2.0.9.1":      false,
+				"2.0.10":       false,
+				"2.1.0":        true,
+				"2.2.3":        true,
+				"2.2.3.1.rc1":  true,
+				"2.2.3.1":      false,
+				"2.2.10":       false,
+				"3.0.0.beta1":  false,
+				"2.2.3.1-java": false,
+				"2.2.3.rc1":    true,
+			} {
+				result, err := advisory.Affects(version)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(affected), version)
+			}
+		})
+
+		context("failure cases", func() {
+			it("returns an error for invalid requirements", func() {
+				_, err := thin.Advisory{ID: "CVE-1", PatchedVersions: []string{">="}}.Affects("1.0.0")
+				Expect(err).To(MatchError(`failed to check advisory CVE-1: invalid gem requirement ">="`))
+			})
+		})
+	})
+}
@@ -1,75 +1,1449 @@
 package thin
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 )
 
-func Build(logger scribe.Emitter) packit.BuildFunc {
+//go:generate faux --interface LockfileParser --output fakes/lockfile_parser.go
+type LockfileParser interface {
+	Parse(path string) (lockfile GemfileLock, err error)
+}
+
+//go:generate faux --interface BindingResolver --output fakes/binding_resolver.go
+type BindingResolver interface {
+	Resolve(typ, provider, platformDir string) ([]servicebindings.Binding, error)
+}
+
+func Build(lockfileParser LockfileParser, bindingResolver BindingResolver, logger scribe.Emitter) packit.BuildFunc {
 	return func(context packit.BuildContext) (packit.BuildResult, error) {
 		logger.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
 
+		lockfile, err := lockfileParser.Parse(filepath.Join(context.WorkingDir, "Gemfile.lock"))
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = checkGemfileDrift(context.WorkingDir, lockfile, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = checkAdvisories(lockfile, bindingResolver, context.Platform.Path, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		rubyVersion, err := ResolveRubyVersion(context.WorkingDir, lockfile)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		var lowestRubyVersion string
+		rubyLowerBound, err := rubyVersion.LowerBound()
+		if err == nil && rubyLowerBound != nil {
+			lowestRubyVersion = rubyLowerBound.String()
+		}
+
+		incompatibilities, err := CheckCompatibility(lockfile, lowestRubyVersion)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if len(incompatibilities) > 0 {
+			return packit.BuildResult{}, packit.Fail.WithMessage("%s", strings.Join(incompatibilities, "; "))
+		}
+
+		gemPaths, err := GemPaths(context.WorkingDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		err = verifyEventMachineExtension(lockfile, gemPaths, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		layer, err := context.Layers.Get("thin")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		layer, err = layer.Reset()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		layer.Launch = true
+
+		procfileWeb, procfileOptions, procfileWebRunsOther, err := procfileThinCommand(filepath.Join(context.WorkingDir, "Procfile"))
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		adoptProcfile := procfileOptions != nil
+
 		rackConfigFilepath := filepath.Join(context.WorkingDir, "config.ru")
-		thinConfigFilepath := os.Getenv("BP_THIN_CONFIG_LOCATION")
-		if thinConfigFilepath != "" {
-			if !filepath.IsAbs(thinConfigFilepath) {
-				thinConfigFilepath = filepath.Join(context.WorkingDir, thinConfigFilepath)
+		thinConfigFilepath := filepath.Join(context.WorkingDir, "thin.yml")
+		inlineThinConfig := os.Getenv("BP_THIN_CONFIG")
+		location := os.Getenv("BP_THIN_CONFIG_LOCATION")
+		if inlineThinConfig != "" && location != "" {
+			return packit.BuildResult{}, packit.Fail.WithMessage("BP_THIN_CONFIG and BP_THIN_CONFIG_LOCATION cannot both be set")
+		}
+
+		// the thin config named by an adopted command takes the place of the
+		// default one
+		procfileConfig, hasProcfileConfig := thinOption(procfileOptions, "-C", "--config")
+		if hasProcfileConfig && (inlineThinConfig != "" || location != "") {
+			return packit.BuildResult{}, packit.Fail.WithMessage("the thin command of the Procfile web entry %q sets its own thin config, which cannot be combined with BP_THIN_CONFIG or BP_THIN_CONFIG_LOCATION", procfileWeb)
+		}
+
+		if inlineThinConfig != "" {
+			config, err := DecodeThinConfig([]byte(inlineThinConfig))
+			if err != nil {
+				return packit.BuildResult{}, packit.Fail.WithMessage("BP_THIN_CONFIG is not a valid YAML or JSON thin config: %s", err)
+			}
+
+			logger.Process("Writing thin config from BP_THIN_CONFIG into the thin layer")
+			logger.Break()
+
+			thinConfigFilepath = filepath.Join(layer.Path, "thin.yml")
+			err = config.Write(thinConfigFilepath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		} else if location != "" {
+			var thinConfigFilepaths []string
+			for _, path := range filepath.SplitList(location) {
+				if path == "" {
+					return packit.BuildResult{}, packit.Fail.WithMessage("BP_THIN_CONFIG_LOCATION contains an empty entry: %q", location)
+				}
+
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(context.WorkingDir, path)
+				}
+
+				thinConfigFilepathExists, err := fs.Exists(path)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				if !thinConfigFilepathExists {
+					return packit.BuildResult{}, packit.Fail.WithMessage("thin config file does not exist at: %s", path)
+				}
+
+				thinConfigFilepaths = append(thinConfigFilepaths, path)
 			}
 
-			thinConfigFilepathExists, err := fs.Exists(thinConfigFilepath)
+			thinConfigFilepath = thinConfigFilepaths[0]
+
+			if len(thinConfigFilepaths) > 1 {
+				thinConfigFilepath, err = mergeThinConfigFiles(layer, thinConfigFilepaths, logger)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			} else if isOutsideDir(context.WorkingDir, thinConfigFilepath) {
+				logger.Process("Copying thin config into the thin layer")
+				logger.Subprocess("WARNING: %s is outside of the application directory and will not be present in the app image", thinConfigFilepath)
+				logger.Break()
+
+				layerThinConfigFilepath := filepath.Join(layer.Path, filepath.Base(thinConfigFilepath))
+				err = fs.Copy(thinConfigFilepath, layerThinConfigFilepath)
+				if err != nil {
+					return packit.BuildResult{}, fmt.Errorf("failed to copy thin config into layer: %w", err)
+				}
+
+				thinConfigFilepath = layerThinConfigFilepath
+			}
+		} else if hasProcfileConfig {
+			if !filepath.IsAbs(procfileConfig) {
+				procfileConfig = filepath.Join(context.WorkingDir, procfileConfig)
+			}
+
+			procfileConfigExists, err := fs.Exists(procfileConfig)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			if !procfileConfigExists {
+				return packit.BuildResult{}, packit.Fail.WithMessage("thin config file does not exist at: %s", procfileConfig)
+			}
+
+			thinConfigFilepath = procfileConfig
+		}
+
+		// thin logs to stdout on a read-only root filesystem as well, unless
+		// file logging is kept
+		readOnlyRoot := os.Getenv("BP_THIN_READ_ONLY_ROOT") == "true"
+		readOnlyLogFile := "/dev/stdout"
+		if os.Getenv("BP_THIN_ALLOW_FILE_LOGS") == "true" {
+			readOnlyLogFile = thinLogFile
+		}
+
+		isRails, err := isRailsApp(context.WorkingDir, lockfile)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		launcher, launcherCommand, err := thinLauncher(context.WorkingDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		logger.Process("Starting thin with the %s launcher", launcher)
+		logger.Break()
+
+		if launcher == "bundler-setup" {
+			layer.LaunchEnv.Default("BUNDLE_GEMFILE", filepath.Join(context.WorkingDir, "Gemfile"))
+		}
+
+		var (
+			preamble    []string
+			configFiles []string
+
+			// configLabel and rackupLabel are the thin config and rackup that
+			// thin is started with, and listenConfig is the config file that
+			// decides where thin listens, for the image labels.
+			configLabel  string
+			rackupLabel  string
+			listenConfig string
+
+			// configOption is the value of the -C option for the resolved thin
+			// config.
+			configOption string
+		)
+
+		info, err := os.Stat(thinConfigFilepath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return packit.BuildResult{}, err
+		}
+		isConfigDir := err == nil && info.IsDir()
+		hasConfig := err == nil
+
+		// the default environment is settled before the config directory is
+		// checked against it, since the environment of a Rails app wins over
+		// the default environment of the directory
+		environment := "production"
+		if defaultEnvironment := os.Getenv("BP_THIN_CONFIG_DEFAULT_ENV"); defaultEnvironment != "" && isConfigDir {
+			environment = defaultEnvironment
+		}
+
+		if isRails {
+			if railsEnv := railsEnvironment(); railsEnv != "" {
+				environment = railsEnv
+			}
+
+			// the overrides of the RAILS_ENV and RACK_ENV launch defaults select
+			// the rails environment at launch as well, so they are subject to
+			// the same rule
+			for _, railsEnv := range []string{environment, os.Getenv("BP_THIN_DEFAULT_RAILS_ENV"), os.Getenv("BP_THIN_DEFAULT_RACK_ENV")} {
+				if railsEnv == "false" {
+					continue
+				}
+
+				err = checkRailsEnvironment(railsEnv)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+		}
+
+		var variants []string
+		if isConfigDir {
+			variants, err = thinConfigVariants(thinConfigFilepath)
 			if err != nil {
 				return packit.BuildResult{}, err
 			}
 
-			if !thinConfigFilepathExists {
-				return packit.BuildResult{}, packit.Fail.WithMessage("thin config file does not exist at: %s", thinConfigFilepath)
+			if !slices.Contains(variants, environment) {
+				return packit.BuildResult{}, packit.Fail.WithMessage("thin config directory %s does not contain a config file for the default environment: %s.yml", thinConfigFilepath, environment)
 			}
+		}
+
+		exists, err := fs.Exists(rackConfigFilepath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		wrapper, err := rackupWrapper(context.WorkingDir, logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		wrapperFilepath := filepath.Join(layer.Path, "config.ru")
+
+		// the generated command is built as the thin options of an adopted
+		// command, so that both go through the same steps below
+		options := procfileOptions
+		if adoptProcfile {
+			logger.Process("Adopting the thin command of the Procfile web entry")
+			logger.Subprocess(procfileWeb)
+			logger.Break()
 		} else {
-			thinConfigFilepath = filepath.Join(context.WorkingDir, "thin.yml")
+			if exists {
+				options = append(options, "-R", rackConfigFilepath)
+			} else if isRails {
+				logger.Process("No config.ru found, starting thin with the rails adapter")
+				logger.Break()
+
+				options = append(options, "-A", "rails")
+			}
+
+			if isRails {
+				options = append(options, "-e", fmt.Sprintf("${RAILS_ENV:-${RACK_ENV:-%s}}", environment))
+			}
+
+			// 3000 is the default thin port
+			options = append(options, "-p", "${PORT:-3000}")
 		}
 
-		args := "bundle exec thin"
+		if hasConfig && !hasProcfileConfig {
+			options = append([]string{"-C", thinConfigFilepath}, options...)
+		}
+
+		// without -R or -A, thin loads the config.ru of the app, which has to
+		// be named to be wrapped
+		_, hasRackup := thinOption(options, "-R", "--rackup")
+		_, hasAdapter := thinOption(options, "-A", "--adapter")
+		if !hasRackup && !hasAdapter && exists {
+			rackupLabel = rackConfigFilepath
+			if wrapper.Enabled() {
+				options = append(options, "-R", rackConfigFilepath)
+			}
+		}
 
-		exists, err := fs.Exists(thinConfigFilepath)
+		thinConfigFilepath, options, err = redirectFileLogs(layer, thinConfigFilepath, options, logger)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		if exists {
-			args = args + fmt.Sprintf(" -C %s", thinConfigFilepath)
+		if readOnlyRoot {
+			thinConfigFilepath, err = relocateRuntimeFiles(layer, thinConfigFilepath, logger)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			options = runtimeFileOptions(options, readOnlyLogFile)
+		}
+
+		if isConfigDir {
+			logger.Process("Selecting thin config from %s at launch", thinConfigFilepath)
+			logger.Subprocess("Available environments: %s", strings.Join(variants, ", "))
+			logger.Subprocess("Default environment: %s", environment)
+			logger.Break()
+
+			preamble = append(preamble,
+				fmt.Sprintf(`thin_env="${THIN_ENV:-${RACK_ENV:-%s}}"`, environment),
+				`if [[ "${thin_env}" == */* ]]; then echo "thin environment '${thin_env}' must not contain '/'" >&2; exit 1; fi`,
+				fmt.Sprintf(`thin_config="%s/${thin_env}.yml"`, thinConfigFilepath),
+				`if [[ ! -f "${thin_config}" ]]; then echo "thin config for environment '${thin_env}' does not exist at ${thin_config}" >&2; exit 1; fi`,
+			)
+			configOption = "${thin_config}"
+
+			for _, variant := range variants {
+				configFiles = append(configFiles, filepath.Join(thinConfigFilepath, variant+".yml"))
+			}
+
+			configLabel = thinConfigFilepath
+			listenConfig = filepath.Join(thinConfigFilepath, environment+".yml")
+		} else if hasConfig {
+			configOption = thinConfigFilepath
+			configFiles = append(configFiles, thinConfigFilepath)
+
+			configLabel = thinConfigFilepath
+			listenConfig = thinConfigFilepath
 		}
 
-		exists, err = fs.Exists(rackConfigFilepath)
+		for i := 0; i+1 < len(options); i++ {
+			switch options[i] {
+			case "-C", "--config":
+				options[i+1] = configOption
+			case "-R", "--rackup":
+				path := options[i+1]
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(context.WorkingDir, path)
+				}
+
+				rackupLabel = path
+				if wrapper.Enabled() {
+					wrapper.Rackup = path
+					options[i+1] = wrapperFilepath
+					rackupLabel = wrapperFilepath
+				}
+			case "-e", "--environment":
+				if isRails {
+					err = checkRailsEnvironment(options[i+1])
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+				}
+			}
+		}
+
+		err = validateRunUser(configFiles, options, logger)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		if exists {
-			args = args + fmt.Sprintf(" -R %s", rackConfigFilepath)
+		if wrapper.Enabled() {
+			if wrapper.Rackup == "" {
+				return packit.BuildResult{}, packit.Fail.WithMessage("the app has no config.ru for %s to wrap", strings.Join(wrapper.Settings(), " and "))
+			}
+
+			logger.Process("Wrapping %s in a generated rackup file", wrapper.Rackup)
+			if wrapper.StatsPath != "" {
+				logger.Subprocess("Serving thin stats at %s with the credentials of the thin-stats service binding at launch", wrapper.StatsPath)
+			}
+			if wrapper.HealthPath != "" {
+				logger.Subprocess("Serving a health check at %s", wrapper.HealthPath)
+			}
+			if wrapper.Gzip {
+				logger.Subprocess("Compressing responses with Rack::Deflater")
+			}
+			for _, dir := range wrapper.StaticDirs {
+				logger.Subprocess("Serving static files from %s (max-age %d)", dir, wrapper.StaticMaxAge)
+			}
+			logger.Break()
+
+			err = wrapper.Write(wrapperFilepath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		if readOnlyRoot {
+			logger.Process("Writing thin pid and log files to /tmp for a read-only root filesystem")
+			logger.Break()
+
+			layer.LaunchEnv.Default("TMPDIR", "/tmp")
+		}
+
+		args := launcherCommand + " " + ShellJoin(options)
+
+		preStart, err := preStartHook(logger)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		preamble = append(preStart, preamble...)
+
+		processType, isDefault, err := thinProcessType()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if procfileWebRunsOther && processType == "web" {
+			logger.Process("WARNING: the Procfile defines a web process that does not run thin, so another buildpack may also define it")
+			logger.Subprocess("Set BP_THIN_PROCESS_TYPE to start thin under a different process type")
+			logger.Break()
 		}
 
-		// 3000 is the default thin port
-		args = args + ` -p "${PORT:-3000}" start`
 		processes := []packit.Process{
 			{
-				Type:    "web",
+				Type:    processType,
 				Command: "bash",
-				Args:    []string{"-c", args},
-				Default: true,
+				Args:    []string{"-c", strings.Join(append(preamble, args+" start"), "; ")},
+				Default: isDefault,
 				Direct:  true,
 			},
 		}
+
+		extraProcesses, err := additionalProcesses(lockfile, strings.Join(append(preamble, args+" --debug --trace start"), "; "))
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+		for _, process := range extraProcesses {
+			if process.Type == processType {
+				return packit.BuildResult{}, packit.Fail.WithMessage("BP_THIN_PROCESS_TYPE %q conflicts with a process type listed in BP_THIN_PROCESSES", processType)
+			}
+		}
+		processes = append(processes, extraProcesses...)
+
 		logger.LaunchProcesses(processes)
 
+		// -W:no-deprecated is only understood by Ruby 2.7 and later, so RUBYOPT
+		// has no default when the app may run an older or unknown Ruby
+		var rubyoptDefault string
+		if rubyLowerBound != nil && !rubyLowerBound.LessThan(semver.MustParse("2.7.0")) {
+			rubyoptDefault = "-W:no-deprecated"
+		}
+
+		for _, variable := range []struct {
+			name  string
+			value string
+		}{
+			{name: "RACK_ENV", value: environment},
+			{name: "RAILS_ENV", value: environment},
+			{name: "LANG", value: "C.UTF-8"},
+			{name: "RUBYOPT", value: rubyoptDefault},
+			{name: "MALLOC_ARENA_MAX", value: "2"},
+		} {
+			value := variable.value
+			if override, ok := os.LookupEnv("BP_THIN_DEFAULT_" + variable.name); ok {
+				if override == "false" {
+					continue
+				}
+
+				value = override
+			}
+
+			if value == "" {
+				continue
+			}
+
+			layer.LaunchEnv.Default(variable.name, value)
+		}
+
+		if os.Getenv("BP_THIN_YJIT") == "true" {
+			if rubyVersion.Version == "" {
+				return packit.BuildResult{}, packit.Fail.WithMessage("BP_THIN_YJIT requires the Ruby version to be declared in the Gemfile.lock, .ruby-version or Gemfile")
+			}
+
+			lowerBound, err := rubyVersion.LowerBound()
+			if err != nil {
+				return packit.BuildResult{}, packit.Fail.WithMessage("cannot interpret Ruby version %q from %s", rubyVersion.Version, rubyVersion.Source)
+			}
+
+			if lowerBound == nil || lowerBound.LessThan(semver.MustParse("3.2.0")) {
+				return packit.BuildResult{}, packit.Fail.WithMessage("YJIT requires Ruby 3.2 or later, but the app declares Ruby %s in %s", rubyVersion.Version, rubyVersion.Source)
+			}
+
+			logger.Process("Enabling YJIT for Ruby %s (from %s)", rubyVersion.Version, rubyVersion.Source)
+			logger.Break()
+
+			layer.LaunchEnv.Default("RUBY_YJIT_ENABLE", "1")
+		}
+
+		if os.Getenv("BP_THIN_JEMALLOC") == "true" {
+			logger.Process("Preloading jemalloc at launch when the run image provides it")
+			logger.Break()
+
+			layer.ExecD = []string{filepath.Join(context.CNBPath, "bin", "preload-jemalloc")}
+		}
+
+		formats, err := sbomFormats(context.BuildpackInfo.SBOMFormats)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if _, ok := lockfile.Gems["thin"]; ok && len(formats) > 0 {
+			logger.GeneratingSBOM(context.WorkingDir)
+			bom, err := GenerateGemSBOM(lockfile, thinGems, gemPaths, context.WorkingDir)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			logger.FormattingSBOM(formats...)
+			layer.SBOM, err = bom.InFormats(formats...)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+		}
+
+		logger.EnvironmentVariables(layer)
+
+		labels, err := thinLabels(lockfile, launcher, configLabel, rackupLabel, listenConfig, options)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		logger.Debug.Process("Image labels:")
+		for _, name := range slices.Sorted(maps.Keys(labels)) {
+			logger.Debug.Subprocess("%s: %s", name, labels[name])
+		}
+		logger.Debug.Break()
+
 		return packit.BuildResult{
+			Layers: []packit.Layer{layer},
 			Launch: packit.LaunchMetadata{
 				Processes: processes,
+				Labels:    labels,
 			},
 		}, nil
 	}
 }
+
+// rackupWrapper returns the rackup wrapper to generate in front of the app.
+// BP_THIN_HEALTH_PATH serves a health check. BP_THIN_GZIP compresses
+// responses, and BP_THIN_STATIC_DIRS serves the files of app directories with
+// a max-age of BP_THIN_STATIC_MAX_AGE seconds. BP_THIN_STATS_PATH serves thin
+// stats, protected with the username and password of the thin-stats service
+// binding that the wrapper reads at launch; without such a binding thin does
+// not start, unless BP_THIN_STATS_ALLOW_UNAUTHENTICATED is true.
+func rackupWrapper(workingDir string, logger scribe.Emitter) (RackupWrapper, error) {
+	wrapper := RackupWrapper{
+		Gzip:         os.Getenv("BP_THIN_GZIP") == "true",
+		StaticMaxAge: 3600,
+	}
+
+	for _, dir := range strings.FieldsFunc(os.Getenv("BP_THIN_STATIC_DIRS"), func(r rune) bool { return r == ',' || r == ' ' }) {
+		path := filepath.Join(workingDir, dir)
+		if filepath.IsAbs(dir) || isOutsideDir(workingDir, path) {
+			return RackupWrapper{}, packit.Fail.WithMessage("BP_THIN_STATIC_DIRS must list directories within the app, got %q", dir)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return RackupWrapper{}, packit.Fail.WithMessage("BP_THIN_STATIC_DIRS lists %q, which does not exist in the app", dir)
+			}
+
+			return RackupWrapper{}, err
+		}
+
+		if !info.IsDir() {
+			return RackupWrapper{}, packit.Fail.WithMessage("BP_THIN_STATIC_DIRS lists %q, which is not a directory", dir)
+		}
+
+		wrapper.StaticDirs = append(wrapper.StaticDirs, path)
+	}
+
+	if value, ok := os.LookupEnv("BP_THIN_STATIC_MAX_AGE"); ok {
+		maxAge, err := strconv.Atoi(value)
+		if err != nil || maxAge < 0 {
+			return RackupWrapper{}, packit.Fail.WithMessage("BP_THIN_STATIC_MAX_AGE must be a number of seconds, got %q", value)
+		}
+		wrapper.StaticMaxAge = maxAge
+	}
+
+	healthPath := os.Getenv("BP_THIN_HEALTH_PATH")
+	if healthPath != "" {
+		if !strings.HasPrefix(healthPath, "/") || healthPath == "/" {
+			return RackupWrapper{}, packit.Fail.WithMessage("BP_THIN_HEALTH_PATH must be a URL path such as /healthz, got %q", healthPath)
+		}
+		wrapper.HealthPath = healthPath
+	}
+
+	statsPath := os.Getenv("BP_THIN_STATS_PATH")
+	if statsPath == "" {
+		return wrapper, nil
+	}
+
+	if !strings.HasPrefix(statsPath, "/") || statsPath == "/" {
+		return RackupWrapper{}, packit.Fail.WithMessage("BP_THIN_STATS_PATH must be a URL path such as /stats, got %q", statsPath)
+	}
+
+	if healthPath == statsPath {
+		return RackupWrapper{}, packit.Fail.WithMessage("BP_THIN_HEALTH_PATH and BP_THIN_STATS_PATH must differ, both are %q", statsPath)
+	}
+	wrapper.StatsPath = statsPath
+
+	wrapper.StatsAllowUnauthenticated = os.Getenv("BP_THIN_STATS_ALLOW_UNAUTHENTICATED") == "true"
+	if wrapper.StatsAllowUnauthenticated {
+		logger.Process("WARNING: thin stats at %s are served without authentication unless a thin-stats service binding is provided at launch", statsPath)
+		logger.Break()
+	}
+
+	return wrapper, nil
+}
+
+// procfileThinCommand returns the web entry of the Procfile at the given path
+// and, if it runs thin, the thin options it passes, which are adopted in place
+// of the generated command. It also reports whether the web entry runs
+// something other than thin.
+func procfileThinCommand(path string) (string, []string, bool, error) {
+	procfile, err := ParseProcfile(path)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	command, ok := procfile["web"]
+	if !ok {
+		return "", nil, false, nil
+	}
+
+	options, runsThin, err := ParseThinCommand(command)
+	if err != nil {
+		return "", nil, false, packit.Fail.WithMessage("failed to adopt the thin command of the Procfile web entry %q: %s", command, err)
+	}
+
+	return command, options, !runsThin, nil
+}
+
+// thinLabels returns the image labels that describe how thin is configured:
+// the locked thin version, the launcher, the thin config and rackup that thin
+// is started with, and the port or UNIX socket it listens on. The listener is
+// taken from the listenConfig thin config file, which wins over the command
+// line options, or else from the thin options, or else it is the default
+// port.
+func thinLabels(lockfile GemfileLock, launcher, config, rackup, listenConfig string, options []string) (map[string]string, error) {
+	labels := map[string]string{
+		"io.paketo.thin.launcher": launcher,
+	}
+
+	if version, ok := lockfile.Gems["thin"]; ok {
+		labels["io.paketo.thin.version"] = version
+	}
+
+	if config != "" {
+		labels["io.paketo.thin.config"] = config
+	}
+
+	if rackup != "" {
+		labels["io.paketo.thin.rackup"] = rackup
+	}
+
+	if listenConfig != "" {
+		exists, err := fs.Exists(listenConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			thinConfig, err := ParseThinConfig(listenConfig)
+			if err != nil {
+				return nil, err
+			}
+
+			if socket, ok := thinConfig.Lookup("socket"); ok {
+				labels["io.paketo.thin.socket"] = fmt.Sprint(socket)
+				return labels, nil
+			}
+
+			if port, ok := thinConfig.Lookup("port"); ok {
+				labels["io.paketo.thin.port"] = fmt.Sprint(port)
+				return labels, nil
+			}
+		}
+	}
+
+	// a port read from PORT is labelled with its default
+	portRe := regexp.MustCompile(`^\$\{PORT:-(\d+)\}$`)
+	labels["io.paketo.thin.port"] = "3000"
+	for i := 0; i+1 < len(options); i++ {
+		value := strings.Trim(options[i+1], `"`)
+
+		switch options[i] {
+		case "-S", "--socket":
+			delete(labels, "io.paketo.thin.port")
+			labels["io.paketo.thin.socket"] = value
+		case "-p", "--port":
+			if matches := portRe.FindStringSubmatch(value); matches != nil {
+				value = matches[1]
+			}
+			labels["io.paketo.thin.port"] = value
+		}
+	}
+
+	return labels, nil
+}
+
+// verifyEventMachineExtension checks that the locked eventmachine gem is
+// installed with its native extension built for the target architecture,
+// since thin cannot serve any request without it. Nothing is checked when
+// eventmachine is not locked or no gem path is set, and only a warning is
+// logged when the gem is not found in the gem paths, since it may be
+// installed somewhere the buildpack does not know about.
+func verifyEventMachineExtension(lockfile GemfileLock, gemPaths []string, logger scribe.Emitter) error {
+	version, ok := lockfile.Gems["eventmachine"]
+	if !ok || len(gemPaths) == 0 {
+		return nil
+	}
+
+	arch := os.Getenv("CNB_TARGET_ARCH")
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+
+	extensions, installed, err := FindEventMachineExtensions(gemPaths, version)
+	if err != nil {
+		return err
+	}
+
+	if !installed {
+		logger.Process("WARNING: eventmachine %s is locked but was not found in %s, so its native extension cannot be checked", version, strings.Join(gemPaths, string(os.PathListSeparator)))
+		logger.Subprocess("Make sure the bundle-install buildpack runs before the thin buildpack")
+		logger.Break()
+
+		return nil
+	}
+
+	paths := slices.Sorted(maps.Keys(extensions))
+	for _, path := range paths {
+		if extensions[path] == arch {
+			logger.Debug.Process("Found the eventmachine native extension for %s at %s", arch, path)
+			logger.Debug.Break()
+
+			return nil
+		}
+	}
+
+	var found []string
+	for _, path := range paths {
+		found = append(found, fmt.Sprintf("%s (%s)", path, extensions[path]))
+	}
+
+	if len(found) == 0 {
+		found = []string{"none"}
+	}
+
+	return packit.Fail.WithMessage("eventmachine %s has no native extension (rubyeventmachine.so) built for %s, found: %s: make sure the build image provides a C++ compiler and the OpenSSL headers so that bundle install can compile it, and that the gem is not installed from another platform", version, arch, strings.Join(found, ", "))
+}
+
+// checkAdvisories checks the locked versions of thin and the gems it runs on
+// against the advisory database at BP_THIN_ADVISORY_DB, or else provided by a
+// ruby-advisory-db service binding. Advisories of BP_THIN_ADVISORY_FAIL_SEVERITY
+// (high by default) or higher fail the build, the others are reported as
+// warnings. Without an advisory database, nothing is checked.
+func checkAdvisories(lockfile GemfileLock, bindingResolver BindingResolver, platformPath string, logger scribe.Emitter) error {
+	dbPath := os.Getenv("BP_THIN_ADVISORY_DB")
+	if dbPath == "" {
+		bindings, err := bindingResolver.Resolve("ruby-advisory-db", "", platformPath)
+		if err != nil {
+			return err
+		}
+
+		if len(bindings) > 1 {
+			return packit.Fail.WithMessage("found %d ruby-advisory-db bindings, but expected at most 1", len(bindings))
+		}
+
+		if len(bindings) == 0 {
+			return nil
+		}
+
+		dbPath = bindings[0].Path
+	}
+
+	failSeverity := os.Getenv("BP_THIN_ADVISORY_FAIL_SEVERITY")
+	if failSeverity == "" {
+		failSeverity = "high"
+	}
+
+	threshold := slices.Index(advisorySeverities, failSeverity)
+	if threshold < 0 {
+		return packit.Fail.WithMessage("BP_THIN_ADVISORY_FAIL_SEVERITY must be one of %s, got %q", strings.Join(advisorySeverities, ", "), failSeverity)
+	}
+
+	gems := []string{"thin", "eventmachine", "rack", "daemons"}
+
+	logger.Process("Checking %s against the advisory database at %s", strings.Join(gems, ", "), dbPath)
+
+	findings, err := CheckAdvisories(dbPath, lockfile, gems)
+	if err != nil {
+		return err
+	}
+
+	if len(findings) == 0 {
+		logger.Subprocess("No advisories found")
+		logger.Break()
+
+		return nil
+	}
+
+	var failures []string
+	for _, finding := range findings {
+		severity := finding.Advisory.Severity()
+		if slices.Index(advisorySeverities, severity) >= threshold {
+			failures = append(failures, finding.Advisory.ID)
+		} else {
+			severity = "WARNING: " + severity
+		}
+
+		patched := "none"
+		if len(finding.Advisory.PatchedVersions) > 0 {
+			patched = strings.Join(finding.Advisory.PatchedVersions, "; ")
+		}
+
+		logger.Subprocess("%s (%s): %s %s: %s", finding.Advisory.ID, severity, finding.Advisory.Gem, finding.Version, finding.Advisory.Title)
+		logger.Action("Patched versions: %s", patched)
+	}
+	logger.Break()
+
+	if len(failures) > 0 {
+		return packit.Fail.WithMessage("found advisories of %s severity or higher: %s", failSeverity, strings.Join(failures, ", "))
+	}
+
+	return nil
+}
+
+// sbomFormats returns the SBOM media types to generate: the formats declared
+// by the buildpack, narrowed down to the formats listed in
+// BP_THIN_SBOM_FORMATS, which may name them by media type or as cyclonedx,
+// spdx or syft.
+func sbomFormats(declared []string) ([]string, error) {
+	selection := os.Getenv("BP_THIN_SBOM_FORMATS")
+	if selection == "" {
+		return declared, nil
+	}
+
+	var formats []string
+	for _, format := range strings.FieldsFunc(selection, func(r rune) bool { return r == ',' || r == ' ' }) {
+		switch format {
+		case "cyclonedx":
+			format = sbom.CycloneDXFormat
+		case "spdx":
+			format = sbom.SPDXFormat
+		case "syft":
+			format = sbom.SyftFormat
+		}
+
+		if !slices.Contains(declared, format) {
+			return nil, packit.Fail.WithMessage("BP_THIN_SBOM_FORMATS contains %q, which is not one of the SBOM formats of the buildpack: %s", format, strings.Join(declared, ", "))
+		}
+
+		if !slices.Contains(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+
+	return formats, nil
+}
+
+// validateRunUser checks that thin can bind its port and keep running as the
+// non-root user of the run image: neither the thin config files nor the
+// options of an adopted Procfile command may set a privileged port or a user
+// or group for thin to switch to. BP_THIN_ALLOW_PRIVILEGED_PORT allows a
+// privileged port for run images that can bind one. A privileged PORT set
+// during the build only results in a warning, since PORT is usually set at
+// launch.
+func validateRunUser(configFiles, options []string, logger scribe.Emitter) error {
+	allowPrivilegedPort := os.Getenv("BP_THIN_ALLOW_PRIVILEGED_PORT") == "true"
+
+	for i := 0; i+1 < len(options); i++ {
+		switch options[i] {
+		case "-u", "--user", "-g", "--group":
+			return packit.Fail.WithMessage("the thin command of the Procfile web entry passes %s, but thin cannot switch to another user or group in the app image: remove it, the app runs as the run image user", options[i])
+		case "-p", "--port":
+			port, err := strconv.Atoi(options[i+1])
+			if err == nil && port < 1024 && !allowPrivilegedPort {
+				return packit.Fail.WithMessage("the thin command of the Procfile web entry passes the privileged port %d, which the non-root run image user cannot bind: use a port of 1024 or higher, e.g. 8080, or set BP_THIN_ALLOW_PRIVILEGED_PORT=true if the run image can bind it", port)
+			}
+		}
+	}
+
+	for _, path := range configFiles {
+		config, err := ParseThinConfig(path)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range []string{"user", "group"} {
+			if _, ok := config.Lookup(name); ok {
+				return packit.Fail.WithMessage("thin config %s sets %q, but thin cannot switch to another %s in the app image: remove it, the app runs as the run image user", path, name, name)
+			}
+		}
+
+		if value, ok := config.Lookup("port"); ok {
+			port, err := strconv.Atoi(fmt.Sprint(value))
+			if err != nil {
+				return packit.Fail.WithMessage("thin config %s sets an invalid port %q", path, fmt.Sprint(value))
+			}
+
+			if port < 1024 && !allowPrivilegedPort {
+				return packit.Fail.WithMessage("thin config %s sets the privileged port %d, which the non-root run image user cannot bind: use a port of 1024 or higher, e.g. 8080, or set BP_THIN_ALLOW_PRIVILEGED_PORT=true if the run image can bind it", path, port)
+			}
+		}
+	}
+
+	if port, err := strconv.Atoi(os.Getenv("PORT")); err == nil && port < 1024 {
+		logger.Process("WARNING: PORT is set to the privileged port %d, which the non-root run image user cannot bind", port)
+		logger.Subprocess("Set PORT to 1024 or higher at launch, e.g. 8080")
+		logger.Break()
+	}
+
+	return nil
+}
+
+// preStartHook returns the launch-time steps that run the BP_THIN_PRE_START
+// command before thin starts, limited to BP_THIN_PRE_START_TIMEOUT and either
+// aborting or continuing the startup when it fails, as selected by
+// BP_THIN_PRE_START_ON_FAILURE.
+func preStartHook(logger scribe.Emitter) ([]string, error) {
+	command := os.Getenv("BP_THIN_PRE_START")
+	if command == "" {
+		return nil, nil
+	}
+
+	timeout := 5 * time.Minute
+	if value := os.Getenv("BP_THIN_PRE_START_TIMEOUT"); value != "" {
+		var err error
+		timeout, err = time.ParseDuration(value)
+		if err != nil || timeout < time.Second {
+			return nil, packit.Fail.WithMessage("BP_THIN_PRE_START_TIMEOUT must be a duration of at least 1s, e.g. 90s or 5m, got %q", value)
+		}
+	}
+
+	onFailure := os.Getenv("BP_THIN_PRE_START_ON_FAILURE")
+	if onFailure == "" {
+		onFailure = "abort"
+	}
+
+	var failureStep string
+	switch onFailure {
+	case "abort":
+		failureStep = `echo "pre-start hook failed, aborting startup" >&2; exit 1`
+	case "continue":
+		failureStep = `echo "pre-start hook failed, starting thin anyway" >&2`
+	default:
+		return nil, packit.Fail.WithMessage("BP_THIN_PRE_START_ON_FAILURE must be abort or continue, got %q", onFailure)
+	}
+
+	logger.Process("Running a pre-start hook before thin starts")
+	logger.Subprocess("Command: %s", command)
+	logger.Subprocess("Timeout: %s", timeout)
+	logger.Subprocess("On failure: %s", onFailure)
+	logger.Break()
+
+	return []string{
+		`echo "Running pre-start hook" >&2`,
+		fmt.Sprintf("if ! timeout %d bash -c %s; then %s; fi", int(timeout.Seconds()), shellQuote(command), failureStep),
+	}, nil
+}
+
+// shellQuote quotes the given string as a single bash word.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// thinProcessType returns the type of the thin process, set through
+// BP_THIN_PROCESS_TYPE, and whether it is the default process, set through
+// BP_THIN_DEFAULT_PROCESS.
+func thinProcessType() (string, bool, error) {
+	processType := "web"
+	if value := os.Getenv("BP_THIN_PROCESS_TYPE"); value != "" {
+		if !processTypeRe.MatchString(value) {
+			return "", false, packit.Fail.WithMessage("BP_THIN_PROCESS_TYPE %q is not a valid process type: it may only contain letters, numbers, '.', '_' and '-'", value)
+		}
+
+		processType = value
+	}
+
+	isDefault := true
+	if value := os.Getenv("BP_THIN_DEFAULT_PROCESS"); value != "" {
+		var err error
+		isDefault, err = strconv.ParseBool(value)
+		if err != nil {
+			return "", false, packit.Fail.WithMessage("BP_THIN_DEFAULT_PROCESS must be true or false, got %q", value)
+		}
+	}
+
+	return processType, isDefault, nil
+}
+
+// processTypeRe matches the process type names allowed by the CNB spec.
+var processTypeRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// additionalProcesses returns the extra process types listed in
+// BP_THIN_PROCESSES, in the order they are listed. debugArgs is the script that
+// starts thin for the web-debug process.
+func additionalProcesses(lockfile GemfileLock, debugArgs string) ([]packit.Process, error) {
+	var processes []packit.Process
+	seen := map[string]bool{}
+	for _, name := range strings.FieldsFunc(os.Getenv("BP_THIN_PROCESSES"), func(r rune) bool { return r == ',' || r == ' ' }) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case "console":
+			console := "irb"
+			if _, ok := lockfile.Gems["racksh"]; ok {
+				console = "racksh"
+			}
+
+			processes = append(processes, packit.Process{
+				Type:    "console",
+				Command: "bundle",
+				Args:    []string{"exec", console},
+				Direct:  true,
+			})
+		case "rake":
+			processes = append(processes, packit.Process{
+				Type:    "rake",
+				Command: "bundle",
+				Args:    []string{"exec", "rake"},
+				Direct:  true,
+			})
+		case "web-debug":
+			processes = append(processes, packit.Process{
+				Type:    "web-debug",
+				Command: "bash",
+				Args:    []string{"-c", debugArgs},
+				Direct:  true,
+			})
+		default:
+			return nil, packit.Fail.WithMessage("BP_THIN_PROCESSES contains an unknown process type %q: it must list console, rake or web-debug", name)
+		}
+	}
+
+	return processes, nil
+}
+
+// thinLauncher returns the launcher selected through BP_THIN_LAUNCHER and the
+// command that starts thin with it. Without a selection, the bin/thin binstub
+// is preferred when the app has one, since it skips the resolution cost of
+// bundle exec.
+func thinLauncher(workingDir string) (string, string, error) {
+	binstub := filepath.Join(workingDir, "bin", "thin")
+	hasBinstub, err := fs.Exists(binstub)
+	if err != nil {
+		return "", "", err
+	}
+
+	launcher := os.Getenv("BP_THIN_LAUNCHER")
+	if launcher == "" {
+		launcher = "bundle-exec"
+		if hasBinstub {
+			launcher = "binstub"
+		}
+	}
+
+	switch launcher {
+	case "bundle-exec":
+		return launcher, "bundle exec thin", nil
+	case "binstub":
+		if !hasBinstub {
+			return "", "", packit.Fail.WithMessage("BP_THIN_LAUNCHER is binstub, but there is no thin binstub at %s", binstub)
+		}
+
+		return launcher, binstub, nil
+	case "bundler-setup":
+		// ruby -S would find thin in PATH before bundler/setup is loaded, so
+		// the thin executable of the bundle is loaded instead
+		return launcher, `ruby -rbundler/setup -e 'load Gem.bin_path("thin", "thin")' --`, nil
+	default:
+		return "", "", packit.Fail.WithMessage("BP_THIN_LAUNCHER must be one of bundle-exec, binstub or bundler-setup, got %q", launcher)
+	}
+}
+
+// mergeThinConfigFiles merges the given thin config files into a single
+// thin.yml in the layer and returns its path.
+func mergeThinConfigFiles(layer packit.Layer, paths []string, logger scribe.Emitter) (string, error) {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+
+		if info.IsDir() {
+			return "", packit.Fail.WithMessage("thin config directories cannot be merged with other thin config files: %s", path)
+		}
+	}
+
+	logger.Process("Merging thin config files")
+	for _, path := range paths {
+		logger.Subprocess(path)
+	}
+	logger.Break()
+
+	merged, provenance, err := MergeThinConfigs(paths...)
+	if err != nil {
+		return "", err
+	}
+
+	names := slices.Sorted(maps.Keys(provenance))
+
+	logger.Debug.Process("Merged thin config:")
+	for _, name := range names {
+		value, _ := merged.Lookup(name)
+		logger.Debug.Subprocess("%s: %v (from %s)", name, value, provenance[name])
+	}
+	logger.Debug.Break()
+
+	path := filepath.Join(layer.Path, "thin.yml")
+	err = merged.Write(path)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// thinPidFile and thinLogFile are the pid and log files of thin on a
+// read-only root filesystem, where only /tmp is writable.
+const (
+	thinPidFile = "/tmp/thin.pid"
+	thinLogFile = "/tmp/thin.log"
+)
+
+// redirectFileLogs rewrites the log option of the thin config at the given
+// path, which may be a file or a per-environment directory, and the log
+// option of the given thin options to /dev/stdout so that log collectors see
+// thin's logs. Configs that are not in the layer yet are rewritten into it,
+// and the path of the rewritten config is returned along with the rewritten
+// options. BP_THIN_ALLOW_FILE_LOGS=true keeps file logging.
+func redirectFileLogs(layer packit.Layer, path string, options []string, logger scribe.Emitter) (string, []string, error) {
+	files, configs, isDir, err := loadThinConfigs(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var fileLogs, fileLogConfigs []string
+	for _, file := range files {
+		if value, ok := configs[file].Lookup("log"); ok && value != "/dev/stdout" && value != "/dev/stderr" {
+			fileLogs = append(fileLogs, fmt.Sprintf("%s: log %v", file, value))
+			fileLogConfigs = append(fileLogConfigs, file)
+		}
+	}
+
+	var fileLogOptions []int
+	for i := 0; i+1 < len(options); i++ {
+		if (options[i] == "-l" || options[i] == "--log") && options[i+1] != "/dev/stdout" && options[i+1] != "/dev/stderr" {
+			fileLogs = append(fileLogs, fmt.Sprintf("thin command: %s %s", options[i], options[i+1]))
+			fileLogOptions = append(fileLogOptions, i+1)
+		}
+	}
+
+	if len(fileLogs) == 0 {
+		return path, options, nil
+	}
+
+	if os.Getenv("BP_THIN_ALLOW_FILE_LOGS") == "true" {
+		logger.Process("WARNING: keeping thin file logging, since BP_THIN_ALLOW_FILE_LOGS is true")
+		for _, fileLog := range fileLogs {
+			logger.Subprocess(fileLog)
+		}
+		logger.Break()
+
+		return path, options, nil
+	}
+
+	logger.Process("Redirecting thin logs to stdout")
+	for _, fileLog := range fileLogs {
+		logger.Subprocess("%s -> /dev/stdout", fileLog)
+	}
+	logger.Subprocess("Set BP_THIN_ALLOW_FILE_LOGS=true to keep logging to files")
+	logger.Break()
+
+	for _, i := range fileLogOptions {
+		options[i] = "/dev/stdout"
+	}
+
+	if len(fileLogConfigs) == 0 {
+		return path, options, nil
+	}
+
+	for _, file := range fileLogConfigs {
+		configs[file]["log"] = "/dev/stdout"
+	}
+
+	path, err = writeThinConfigs(layer, path, isDir, files, configs)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return path, options, nil
+}
+
+// relocateRuntimeFiles rewrites the pid option and any file log option of
+// the thin config at the given path, which may be a file or a per-environment
+// directory, to files in /tmp, since thin loads them over the --pid and --log
+// options and the app directory is not writable on a read-only root
+// filesystem. Like redirectFileLogs, it returns the path of the rewritten
+// config.
+func relocateRuntimeFiles(layer packit.Layer, path string, logger scribe.Emitter) (string, error) {
+	files, configs, isDir, err := loadThinConfigs(path)
+	if err != nil || files == nil {
+		return path, err
+	}
+
+	relocations := map[string]string{"pid": thinPidFile, "log": thinLogFile}
+
+	var rewritten []string
+	for _, file := range files {
+		for _, name := range []string{"pid", "log"} {
+			value, ok := configs[file].Lookup(name)
+			if !ok || value == relocations[name] || value == "/dev/stdout" || value == "/dev/stderr" {
+				continue
+			}
+
+			rewritten = append(rewritten, fmt.Sprintf("%s: %s %v -> %s", file, name, value, relocations[name]))
+			configs[file][name] = relocations[name]
+		}
+	}
+
+	if len(rewritten) == 0 {
+		return path, nil
+	}
+
+	logger.Process("Relocating thin runtime files to /tmp for a read-only root filesystem")
+	for _, line := range rewritten {
+		logger.Subprocess(line)
+	}
+	logger.Break()
+
+	return writeThinConfigs(layer, path, isDir, files, configs)
+}
+
+// loadThinConfigs parses the thin config at the given path, which may be a
+// file or a per-environment directory, and returns its files along with
+// their configs, and whether the path is a directory. It returns no files if
+// there is no config at the path.
+func loadThinConfigs(path string) ([]string, map[string]ThinConfig, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, false, nil
+		}
+
+		return nil, nil, false, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.yml"))
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	configs := map[string]ThinConfig{}
+	for _, file := range files {
+		config, err := ParseThinConfig(file)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		configs[file] = config
+	}
+
+	return files, configs, info.IsDir(), nil
+}
+
+// writeThinConfigs writes the given configs of the thin config at the given
+// path back, into the layer if the config is not in it yet, and returns the
+// path of the written config.
+func writeThinConfigs(layer packit.Layer, path string, isDir bool, files []string, configs map[string]ThinConfig) (string, error) {
+	target := path
+	if isOutsideDir(layer.Path, path) {
+		target = filepath.Join(layer.Path, filepath.Base(path))
+		if isDir {
+			err := os.MkdirAll(target, os.ModePerm)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	for _, file := range files {
+		destination := target
+		if isDir {
+			destination = filepath.Join(target, filepath.Base(file))
+		}
+
+		err := configs[file].Write(destination)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return target, nil
+}
+
+// runtimeFileOptions rewrites the pid option and any file log option of an
+// adopted thin command to files in /tmp, and adds the options the command
+// does not pass, logging to the given file, so that thin does not write to
+// the app directory on a read-only root filesystem.
+func runtimeFileOptions(options []string, logFile string) []string {
+	var hasPid, hasLog bool
+	for i := 0; i+1 < len(options); i++ {
+		switch options[i] {
+		case "-P", "--pid":
+			hasPid = true
+			options[i+1] = thinPidFile
+		case "-l", "--log":
+			hasLog = true
+			if options[i+1] != "/dev/stdout" && options[i+1] != "/dev/stderr" {
+				options[i+1] = thinLogFile
+			}
+		}
+	}
+
+	if !hasPid {
+		options = append(options, "--pid", thinPidFile)
+	}
+
+	if !hasLog {
+		options = append(options, "--log", logFile)
+	}
+
+	return options
+}
+
+// isOutsideDir reports whether the given path lies outside of dir.
+func isOutsideDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return true
+	}
+
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// thinConfigVariants returns the names of the environments that have a thin
+// config file (<environment>.yml) in the given directory.
+func thinConfigVariants(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var variants []string
+	for _, match := range matches {
+		variants = append(variants, strings.TrimSuffix(filepath.Base(match), ".yml"))
+	}
+
+	if len(variants) == 0 {
+		return nil, packit.Fail.WithMessage("thin config directory does not contain any .yml files: %s", dir)
+	}
+
+	return variants, nil
+}
+
+// isRailsApp reports whether the app is a Rails app: it must carry a
+// config/application.rb and lock either rails or railties.
+func isRailsApp(workingDir string, lockfile GemfileLock) (bool, error) {
+	exists, err := fs.Exists(filepath.Join(workingDir, "config", "application.rb"))
+	if err != nil {
+		return false, err
+	}
+
+	if !exists {
+		return false, nil
+	}
+
+	_, hasRails := lockfile.Gems["rails"]
+	_, hasRailties := lockfile.Gems["railties"]
+
+	return hasRails || hasRailties, nil
+}
+
+// railsEnvironment returns the environment set through RAILS_ENV or RACK_ENV
+// during the build, if any.
+func railsEnvironment() string {
+	environment := os.Getenv("RAILS_ENV")
+	if environment == "" {
+		environment = os.Getenv("RACK_ENV")
+	}
+
+	return environment
+}
+
+// checkRailsEnvironment returns an error if the given rails environment is
+// not production, unless BP_THIN_ALLOW_NON_PRODUCTION_ENV is true. A variable
+// reference is settled at launch by the launch defaults, which are checked on
+// their own.
+func checkRailsEnvironment(environment string) error {
+	if environment == "" || environment == "production" || strings.Contains(environment, "$") {
+		return nil
+	}
+
+	if os.Getenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV") != "true" {
+		return packit.Fail.WithMessage("rails environment %q is not production: set BP_THIN_ALLOW_NON_PRODUCTION_ENV=true to allow it", environment)
+	}
+
+	return nil
+}
@@ -2,14 +2,21 @@ package thin_test
 
 import (
 	"bytes"
+	"debug/elf"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 	"github.com/paketo-buildpacks/thin"
+	"github.com/paketo-buildpacks/thin/fakes"
 	"github.com/sclevine/spec"
 
 	. "github.com/onsi/gomega"
@@ -24,6 +31,9 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		cnbDir     string
 		buffer     *bytes.Buffer
 
+		lockfileParser  *fakes.LockfileParser
+		bindingResolver *fakes.BindingResolver
+
 		build        packit.BuildFunc
 		buildContext packit.BuildContext
 	)
@@ -42,10 +52,14 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		buffer = bytes.NewBuffer(nil)
 		logger := scribe.NewEmitter(buffer)
 
-		build = thin.Build(logger)
+		lockfileParser = &fakes.LockfileParser{}
+		bindingResolver = &fakes.BindingResolver{}
+
+		build = thin.Build(lockfileParser, bindingResolver, logger)
 		buildContext = packit.BuildContext{
 			WorkingDir: workingDir,
 			CNBPath:    cnbDir,
+			Platform:   packit.Platform{Path: "some-platform-path"},
 			Stack:      "some-stack",
 			BuildpackInfo: packit.BuildpackInfo{
 				Name:    "Some Buildpack",
@@ -72,7 +86,22 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			Plan: packit.BuildpackPlan{
 				Entries: nil,
 			},
-			Layers: nil,
+			Layers: []packit.Layer{
+				{
+					Path:      filepath.Join(layersDir, "thin"),
+					Name:      "thin",
+					Launch:    true,
+					SharedEnv: packit.Environment{},
+					BuildEnv:  packit.Environment{},
+					LaunchEnv: packit.Environment{
+						"RACK_ENV.default":         "production",
+						"RAILS_ENV.default":        "production",
+						"LANG.default":             "C.UTF-8",
+						"MALLOC_ARENA_MAX.default": "2",
+					},
+					ProcessLaunchEnv: map[string]packit.Environment{},
+				},
+			},
 			Launch: packit.LaunchMetadata{
 				Processes: []packit.Process{
 					{
@@ -83,199 +112,2535 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 						Direct:  true,
 					},
 				},
+				Labels: map[string]string{
+					"io.paketo.thin.launcher": "bundle-exec",
+					"io.paketo.thin.port":     "3000",
+				},
 			},
 		}))
 
+		Expect(lockfileParser.ParseCall.Receives.Path).To(Equal(filepath.Join(workingDir, "Gemfile.lock")))
+
 		Expect(buffer.String()).To(ContainSubstring("Some Buildpack some-version"))
 		Expect(buffer.String()).To(ContainSubstring("Assigning launch processes:"))
 	})
 
-	context("when a thin.yml file exists in the working directory", func() {
+	context("when the BP_THIN_DEFAULT_* environment variables are set", func() {
 		it.Before(func() {
-			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte{}, os.ModePerm)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_DEFAULT_LANG", "false")).To(Succeed())
+			Expect(os.Setenv("BP_THIN_DEFAULT_RUBYOPT", "--yjit-stats")).To(Succeed())
+			Expect(os.Setenv("BP_THIN_DEFAULT_MALLOC_ARENA_MAX", "4")).To(Succeed())
 		})
 
-		it("returns a result with that file provided to the thin start command", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_DEFAULT_LANG")).To(Succeed())
+			Expect(os.Unsetenv("BP_THIN_DEFAULT_RUBYOPT")).To(Succeed())
+			Expect(os.Unsetenv("BP_THIN_DEFAULT_MALLOC_ARENA_MAX")).To(Succeed())
+		})
+
+		it("opts out of or overrides the launch environment defaults", func() {
 			result, err := build(buildContext)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(result).To(Equal(packit.BuildResult{
-				Plan: packit.BuildpackPlan{
-					Entries: nil,
-				},
-				Layers: nil,
-				Launch: packit.LaunchMetadata{
-					Processes: []packit.Process{
-						{
-							Type:    "web",
-							Command: "bash",
-							Args: []string{
-								"-c",
-								fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "thin.yml")),
-							},
-							Default: true,
-							Direct:  true,
-						},
-					},
-				},
+			Expect(result.Layers).To(HaveLen(1))
+			Expect(result.Layers[0].LaunchEnv).To(Equal(packit.Environment{
+				"RACK_ENV.default":         "production",
+				"RAILS_ENV.default":        "production",
+				"RUBYOPT.default":          "--yjit-stats",
+				"MALLOC_ARENA_MAX.default": "4",
 			}))
+
+			Expect(buffer.String()).To(ContainSubstring("Configuring launch environment"))
+			Expect(buffer.String()).To(ContainSubstring(`MALLOC_ARENA_MAX -> "4"`))
+			Expect(buffer.String()).NotTo(ContainSubstring("LANG"))
 		})
 	})
 
-	context("when both thin.yml and config.ru files exist in the working directory", func() {
+	context("when the app declares a Ruby version", func() {
 		it.Before(func() {
-			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte{}, os.ModePerm)).To(Succeed())
-			Expect(os.WriteFile(filepath.Join(workingDir, "config.ru"), []byte{}, os.ModePerm)).To(Succeed())
+			lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+				RubyVersion: "3.2.2p53",
+			}
 		})
 
-		it("returns a result with both files provided to the thin start command", func() {
+		it("defaults RUBYOPT to silence deprecation warnings", func() {
 			result, err := build(buildContext)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(result).To(Equal(packit.BuildResult{
-				Plan: packit.BuildpackPlan{
-					Entries: nil,
-				},
-				Layers: nil,
-				Launch: packit.LaunchMetadata{
-					Processes: []packit.Process{
-						{
-							Type:    "web",
-							Command: "bash",
-							Args: []string{
-								"-c",
-								fmt.Sprintf(`bundle exec thin -C %s -R %s -p "${PORT:-3000}" start`,
-									filepath.Join(workingDir, "thin.yml"),
-									filepath.Join(workingDir, "config.ru"),
-								),
-							},
-							Default: true,
-							Direct:  true,
-						},
-					},
-				},
-			}))
+			Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("RUBYOPT.default", "-W:no-deprecated"))
+		})
+
+		context("when the Ruby version is older than 2.7", func() {
+			it.Before(func() {
+				lockfileParser.ParseCall.Returns.Lockfile.RubyVersion = "2.6.10p210"
+			})
+
+			it("does not default RUBYOPT", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].LaunchEnv).NotTo(HaveKey("RUBYOPT.default"))
+			})
+		})
+
+		context("when BP_THIN_DEFAULT_RUBYOPT is false", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_DEFAULT_RUBYOPT", "false")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_DEFAULT_RUBYOPT")).To(Succeed())
+			})
+
+			it("opts out of the RUBYOPT default", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].LaunchEnv).NotTo(HaveKey("RUBYOPT.default"))
+			})
 		})
 	})
 
-	context("when the BP_THIN_CONFIG_LOCATION environment variable points to a valid file", func() {
+	context("when the BP_THIN_YJIT environment variable is true", func() {
 		it.Before(func() {
-			thinConfigFilepath := filepath.Join(workingDir, "some-thin-config.yml")
-			Expect(os.WriteFile(thinConfigFilepath, []byte{}, os.ModePerm)).To(Succeed())
-			Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", thinConfigFilepath)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_YJIT", "true")).To(Succeed())
+
+			lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+				RubyVersion: "3.3.0p0",
+			}
 		})
 
 		it.After(func() {
-			Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+			Expect(os.Unsetenv("BP_THIN_YJIT")).To(Succeed())
 		})
 
-		it("returns a result with that file provided to the thin start command", func() {
+		it("enables YJIT in the launch environment", func() {
 			result, err := build(buildContext)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(result).To(Equal(packit.BuildResult{
-				Plan: packit.BuildpackPlan{
-					Entries: nil,
-				},
-				Layers: nil,
-				Launch: packit.LaunchMetadata{
-					Processes: []packit.Process{
-						{
-							Type:    "web",
-							Command: "bash",
-							Args: []string{
-								"-c",
-								fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "some-thin-config.yml")),
-							},
-							Default: true,
-							Direct:  true,
-						},
-					},
-				},
-			}))
+			Expect(result.Layers).To(HaveLen(1))
+			Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("RUBY_YJIT_ENABLE.default", "1"))
+
+			Expect(buffer.String()).To(ContainSubstring("Enabling YJIT for Ruby 3.3.0p0 (from Gemfile.lock)"))
 		})
 
-		context("when the BP_THIN_CONFIG_LOCATION environment variable is a relative path", func() {
+		context("when the app declares a prerelease Ruby version", func() {
 			it.Before(func() {
-				relativeFilepath := filepath.Join("some-dir", "some-thin-config.yml")
-				Expect(os.MkdirAll(filepath.Join(workingDir, "some-dir"), os.ModePerm)).To(Succeed())
-				Expect(os.WriteFile(filepath.Join(workingDir, "some-dir", "some-thin-config.yml"), []byte{}, os.ModePerm)).To(Succeed())
-				Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", relativeFilepath)).To(Succeed())
+				lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+					RubyVersion: "3.4.0.preview1",
+				}
+			})
+
+			it("enables YJIT in the launch environment", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("RUBY_YJIT_ENABLE.default", "1"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the Ruby version does not support YJIT", func() {
+				it.Before(func() {
+					lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+						RubyVersion: "3.1.3p185",
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("YJIT requires Ruby 3.2 or later, but the app declares Ruby 3.1.3p185 in Gemfile.lock")))
+				})
+			})
+
+			context("when the Ruby version cannot be determined", func() {
+				it.Before(func() {
+					lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{}
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("BP_THIN_YJIT requires the Ruby version to be declared in the Gemfile.lock, .ruby-version or Gemfile")))
+				})
+			})
+
+			context("when the Ruby version cannot be interpreted", func() {
+				it.Before(func() {
+					lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+						RubyVersion: "head",
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`cannot interpret Ruby version "head" from Gemfile.lock`)))
+				})
+			})
+		})
+	})
+
+	context("when the BP_THIN_JEMALLOC environment variable is true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_THIN_JEMALLOC", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_JEMALLOC")).To(Succeed())
+		})
+
+		it("adds the jemalloc preload helper to the launch layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(1))
+			Expect(result.Layers[0].ExecD).To(Equal([]string{filepath.Join(cnbDir, "bin", "preload-jemalloc")}))
+
+			Expect(buffer.String()).To(ContainSubstring("Preloading jemalloc at launch when the run image provides it"))
+		})
+	})
+
+	context("when the app has a thin binstub", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "bin"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "bin", "thin"), nil, 0755)).To(Succeed())
+		})
+
+		it("starts thin with the binstub", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes).To(HaveLen(1))
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`%s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "bin", "thin"))}))
+
+			Expect(buffer.String()).To(ContainSubstring("Starting thin with the binstub launcher"))
+		})
+
+		context("when the BP_THIN_LAUNCHER environment variable is bundle-exec", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_LAUNCHER", "bundle-exec")).To(Succeed())
 			})
 
 			it.After(func() {
-				Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+				Expect(os.Unsetenv("BP_THIN_LAUNCHER")).To(Succeed())
 			})
 
-			it("returns a result with that file relative to the working directory", func() {
+			it("starts thin with bundle exec", func() {
 				result, err := build(buildContext)
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(result).To(Equal(packit.BuildResult{
-					Plan: packit.BuildpackPlan{
-						Entries: nil,
-					},
-					Layers: nil,
-					Launch: packit.LaunchMetadata{
-						Processes: []packit.Process{
-							{
-								Type:    "web",
-								Command: "bash",
-								Args: []string{
-									"-c",
-									fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "some-dir", "some-thin-config.yml")),
-								},
-								Default: true,
-								Direct:  true,
-							},
-						},
-					},
-				}))
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", `bundle exec thin -p "${PORT:-3000}" start`}))
+
+				Expect(buffer.String()).To(ContainSubstring("Starting thin with the bundle-exec launcher"))
 			})
 		})
 	})
 
-	context("when both the BP_THIN_CONFIG_LOCATION env var is set and a thin.yml file is present", func() {
+	context("when the BP_THIN_LAUNCHER environment variable is bundler-setup", func() {
 		it.Before(func() {
-			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte{}, os.ModePerm)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_LAUNCHER", "bundler-setup")).To(Succeed())
+		})
 
-			envVarThinConfigFilepath := filepath.Join(workingDir, "some-thin-config.yml")
-			Expect(os.WriteFile(envVarThinConfigFilepath, []byte{}, os.ModePerm)).To(Succeed())
-			Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", envVarThinConfigFilepath)).To(Succeed())
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_LAUNCHER")).To(Succeed())
+		})
+
+		it("starts thin with bundler/setup and the app Gemfile", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", `ruby -rbundler/setup -e 'load Gem.bin_path("thin", "thin")' -- -p "${PORT:-3000}" start`}))
+			Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("BUNDLE_GEMFILE.default", filepath.Join(workingDir, "Gemfile")))
+
+			Expect(buffer.String()).To(ContainSubstring("Starting thin with the bundler-setup launcher"))
+		})
+	})
+
+	context("when the BP_THIN_PROCESSES environment variable is set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_THIN_PROCESSES", "rake, console,web-debug")).To(Succeed())
 		})
 
 		it.After(func() {
-			Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+			Expect(os.Unsetenv("BP_THIN_PROCESSES")).To(Succeed())
 		})
 
-		it("prioritizes the environment variable", func() {
+		it("adds the listed process types", func() {
 			result, err := build(buildContext)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(result).To(Equal(packit.BuildResult{
-				Plan: packit.BuildpackPlan{
-					Entries: nil,
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{
+					Type:    "web",
+					Command: "bash",
+					Args:    []string{"-c", `bundle exec thin -p "${PORT:-3000}" start`},
+					Default: true,
+					Direct:  true,
 				},
-				Layers: nil,
-				Launch: packit.LaunchMetadata{
-					Processes: []packit.Process{
-						{
-							Type:    "web",
-							Command: "bash",
-							Args: []string{
-								"-c",
-								fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "some-thin-config.yml")),
-							},
-							Default: true,
-							Direct:  true,
-						},
-					},
+				{
+					Type:    "rake",
+					Command: "bundle",
+					Args:    []string{"exec", "rake"},
+					Direct:  true,
+				},
+				{
+					Type:    "console",
+					Command: "bundle",
+					Args:    []string{"exec", "irb"},
+					Direct:  true,
+				},
+				{
+					Type:    "web-debug",
+					Command: "bash",
+					Args:    []string{"-c", `bundle exec thin -p "${PORT:-3000}" --debug --trace start`},
+					Direct:  true,
 				},
 			}))
 		})
+
+		context("when the app locks racksh", func() {
+			it.Before(func() {
+				lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+					Gems: map[string]string{"racksh": "1.0.1"},
+				}
+			})
+
+			it("uses racksh for the console process", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[2]).To(Equal(packit.Process{
+					Type:    "console",
+					Command: "bundle",
+					Args:    []string{"exec", "racksh"},
+					Direct:  true,
+				}))
+			})
+		})
 	})
 
-	context("failure cases", func() {
+	context("when the BP_THIN_PROCESS_TYPE and BP_THIN_DEFAULT_PROCESS environment variables are set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_THIN_PROCESS_TYPE", "thin")).To(Succeed())
+			Expect(os.Setenv("BP_THIN_DEFAULT_PROCESS", "false")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_PROCESS_TYPE")).To(Succeed())
+			Expect(os.Unsetenv("BP_THIN_DEFAULT_PROCESS")).To(Succeed())
+		})
+
+		it("names the thin process and does not make it the default", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{
+					Type:    "thin",
+					Command: "bash",
+					Args:    []string{"-c", `bundle exec thin -p "${PORT:-3000}" start`},
+					Default: false,
+					Direct:  true,
+				},
+			}))
+		})
+	})
+
+	context("when the Gemfile and Gemfile.lock disagree about thin", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte("source 'https://rubygems.org'\ngem 'thin'\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile.lock"), []byte("GEM\n  specs:\n    rack (2.2.8)\n\nDEPENDENCIES\n  rack\n"), 0600)).To(Succeed())
+
+			lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+				Gems:         map[string]string{"rack": "2.2.8"},
+				GemLines:     map[string]int{"rack": 3},
+				Dependencies: map[string]thin.GemfileLockDependency{"rack": {Line: 6}},
+			}
+		})
+
+		it("warns about the drift", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring("WARNING: the Gemfile and Gemfile.lock disagree about thin"))
+			Expect(buffer.String()).To(ContainSubstring("Gemfile:2 lists thin but the DEPENDENCIES of Gemfile.lock do not"))
+		})
+	})
+
+	context("when the app has a Procfile whose web entry does not run thin", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec puma\n"), 0600)).To(Succeed())
+		})
+
+		it("warns that another buildpack may also define the web process", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", `bundle exec thin -p "${PORT:-3000}" start`}))
+
+			Expect(buffer.String()).To(ContainSubstring("WARNING: the Procfile defines a web process that does not run thin, so another buildpack may also define it"))
+		})
+	})
+
+	context("when the app has a Procfile whose web entry runs thin", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -R config.ru -p $PORT --timeout=30\nworker: bundle exec rake jobs:work\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), nil, 0600)).To(Succeed())
+		})
+
+		it("adopts the thin command of the Procfile", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{
+					Type:    "web",
+					Command: "bash",
+					Args:    []string{"-c", fmt.Sprintf(`bundle exec thin -C %s -R config.ru -p "${PORT:-3000}" --timeout 30 start`, filepath.Join(workingDir, "thin.yml"))},
+					Default: true,
+					Direct:  true,
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("Adopting the thin command of the Procfile web entry"))
+			Expect(buffer.String()).NotTo(ContainSubstring("WARNING"))
+		})
+
+		context("when the command quotes its options", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte(`web: bundle exec thin start -p "$PORT" --tag 'my app'`+"\n"), 0600)).To(Succeed())
+			})
+
+			it("adopts the thin command of the Procfile", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes).To(HaveLen(1))
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" --tag 'my app' start`, filepath.Join(workingDir, "thin.yml"))}))
+
+				Expect(buffer.String()).To(ContainSubstring("Adopting the thin command of the Procfile web entry"))
+				Expect(buffer.String()).NotTo(ContainSubstring("WARNING"))
+			})
+		})
+
+		context("when the command names a thin config", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -C cfg.yml\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "cfg.yml"), []byte("port: 8080\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte("port: 80\n"), 0600)).To(Succeed())
+			})
+
+			it("validates that config instead of the default one", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes).To(HaveLen(1))
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "cfg.yml"))}))
+			})
+
+			context("when that config sets a user", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "cfg.yml"), []byte("user: root\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`thin config %s sets "user", but thin cannot switch to another user in the app image: remove it, the app runs as the run image user`, filepath.Join(workingDir, "cfg.yml"))))
+				})
+			})
+
+			context("when that config does not exist", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "cfg.yml"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("thin config file does not exist at: %s", filepath.Join(workingDir, "cfg.yml"))))
+				})
+			})
+		})
+
+		context("when BP_THIN_CONFIG is set", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -p $PORT\n"), 0600)).To(Succeed())
+				Expect(os.Setenv("BP_THIN_CONFIG", "timeout: 5")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_CONFIG")).To(Succeed())
+			})
+
+			it("passes the thin config to the command", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes).To(HaveLen(1))
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(layersDir, "thin", "thin.yml"))}))
+			})
+
+			context("when the command names a thin config", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -C cfg.yml\n"), 0600)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "cfg.yml"), nil, 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`the thin command of the Procfile web entry "bundle exec thin start -C cfg.yml" sets its own thin config, which cannot be combined with BP_THIN_CONFIG or BP_THIN_CONFIG_LOCATION`)))
+				})
+			})
+		})
+
+		context("when BP_THIN_CONFIG_LOCATION points to a directory", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -p $PORT\n"), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "config", "thin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "production.yml"), []byte("port: 8080\n"), 0600)).To(Succeed())
+				Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", "config/thin")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+			})
+
+			it("selects the thin config of the environment for the command at launch", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes).To(HaveLen(1))
+				Expect(result.Launch.Processes[0].Args).To(HaveLen(2))
+				Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring(fmt.Sprintf(`thin_config="%s/${thin_env}.yml"`, filepath.Join(workingDir, "config", "thin"))))
+				Expect(result.Launch.Processes[0].Args[1]).To(HaveSuffix(`bundle exec thin -C "${thin_config}" -p "${PORT:-3000}" start`))
+			})
+
+			context("when the config of the environment sets a privileged port", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "production.yml"), []byte("port: 80\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("thin config %s sets the privileged port 80, which the non-root run image user cannot bind: use a port of 1024 or higher, e.g. 8080, or set BP_THIN_ALLOW_PRIVILEGED_PORT=true if the run image can bind it", filepath.Join(workingDir, "config", "thin", "production.yml"))))
+				})
+			})
+		})
+
+		context("when the command sets a user or group", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -p 8080 -u root -g root\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage("the thin command of the Procfile web entry passes -u, but thin cannot switch to another user or group in the app image: remove it, the app runs as the run image user")))
+			})
+		})
+
+		context("when the command passes a privileged port", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -p 80\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage("the thin command of the Procfile web entry passes the privileged port 80, which the non-root run image user cannot bind: use a port of 1024 or higher, e.g. 8080, or set BP_THIN_ALLOW_PRIVILEGED_PORT=true if the run image can bind it")))
+			})
+		})
+	})
+
+	context("when the BP_THIN_PRE_START environment variable is set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_THIN_PRE_START", "bundle exec rake db:migrate && echo 'migrated'")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_PRE_START")).To(Succeed())
+		})
+
+		it("runs the pre-start hook before thin starts and aborts if it fails", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", strings.Join([]string{
+				`echo "Running pre-start hook" >&2`,
+				`if ! timeout 300 bash -c 'bundle exec rake db:migrate && echo '\''migrated'\'''; then echo "pre-start hook failed, aborting startup" >&2; exit 1; fi`,
+				`bundle exec thin -p "${PORT:-3000}" start`,
+			}, "; ")}))
+
+			Expect(buffer.String()).To(ContainSubstring("Running a pre-start hook before thin starts"))
+			Expect(buffer.String()).To(ContainSubstring("Timeout: 5m0s"))
+			Expect(buffer.String()).To(ContainSubstring("On failure: abort"))
+		})
+
+		context("when the timeout and failure behaviour are configured", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_PRE_START_TIMEOUT", "90s")).To(Succeed())
+				Expect(os.Setenv("BP_THIN_PRE_START_ON_FAILURE", "continue")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_PRE_START_TIMEOUT")).To(Succeed())
+				Expect(os.Unsetenv("BP_THIN_PRE_START_ON_FAILURE")).To(Succeed())
+			})
+
+			it("limits the pre-start hook to the timeout and starts thin even if it fails", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring(`if ! timeout 90 bash -c `))
+				Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring(`then echo "pre-start hook failed, starting thin anyway" >&2; fi; bundle exec thin`))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the timeout is not a valid duration", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_PRE_START_TIMEOUT", "forever")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_PRE_START_TIMEOUT")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_PRE_START_TIMEOUT must be a duration of at least 1s, e.g. 90s or 5m, got "forever"`)))
+				})
+			})
+
+			context("when the failure behaviour is unknown", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_PRE_START_ON_FAILURE", "retry")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_PRE_START_ON_FAILURE")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_PRE_START_ON_FAILURE must be abort or continue, got "retry"`)))
+				})
+			})
+		})
+	})
+
+	context("when the thin config sets the port", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte("port: 8080\n"), 0600)).To(Succeed())
+		})
+
+		it("accepts an unprivileged port", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		context("when the port is privileged", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte("port: 80\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage("thin config %s sets the privileged port 80, which the non-root run image user cannot bind: use a port of 1024 or higher, e.g. 8080, or set BP_THIN_ALLOW_PRIVILEGED_PORT=true if the run image can bind it", filepath.Join(workingDir, "thin.yml"))))
+			})
+
+			context("when the BP_THIN_ALLOW_PRIVILEGED_PORT environment variable is true", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_ALLOW_PRIVILEGED_PORT", "true")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_ALLOW_PRIVILEGED_PORT")).To(Succeed())
+				})
+
+				it("accepts the port", func() {
+					_, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
+		})
+	})
+
+	context("when the PORT environment variable is privileged during the build", func() {
+		it.Before(func() {
+			Expect(os.Setenv("PORT", "443")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("PORT")).To(Succeed())
+		})
+
+		it("warns that the run image user cannot bind it", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buffer.String()).To(ContainSubstring("WARNING: PORT is set to the privileged port 443, which the non-root run image user cannot bind"))
+		})
+	})
+
+	context("when the BP_THIN_READ_ONLY_ROOT environment variable is true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_THIN_READ_ONLY_ROOT", "true")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_READ_ONLY_ROOT")).To(Succeed())
+		})
+
+		it("writes the thin pid and log files to /tmp", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", `bundle exec thin -p "${PORT:-3000}" --pid /tmp/thin.pid --log /dev/stdout start`}))
+			Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("TMPDIR.default", "/tmp"))
+
+			Expect(buffer.String()).To(ContainSubstring("Writing thin pid and log files to /tmp for a read-only root filesystem"))
+		})
+
+		context("when the BP_THIN_ALLOW_FILE_LOGS environment variable is true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_ALLOW_FILE_LOGS", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_ALLOW_FILE_LOGS")).To(Succeed())
+			})
+
+			it("writes the thin log file to /tmp", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", `bundle exec thin -p "${PORT:-3000}" --pid /tmp/thin.pid --log /tmp/thin.log start`}))
+			})
+		})
+
+		context("when the thin config sets the pid file", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte("pid: tmp/pids/thin.pid\ntimeout: 30\n"), 0600)).To(Succeed())
+			})
+
+			it("rewrites the pid option to /tmp in a copy of the config", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				layerThinConfig := filepath.Join(layersDir, "thin", "thin.yml")
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" --pid /tmp/thin.pid --log /dev/stdout start`, layerThinConfig)}))
+
+				config, err := thin.ParseThinConfig(layerThinConfig)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config).To(Equal(thin.ThinConfig{"pid": "/tmp/thin.pid", "timeout": 30}))
+
+				Expect(buffer.String()).To(ContainSubstring("Relocating thin runtime files to /tmp for a read-only root filesystem"))
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("%s: pid tmp/pids/thin.pid -> /tmp/thin.pid", filepath.Join(workingDir, "thin.yml"))))
+			})
+		})
+
+		context("when the thin command of the Procfile web entry is adopted", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -C cfg.yml --pid tmp/pids/thin.pid\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "cfg.yml"), []byte("pid: tmp/pids/thin.pid\n"), 0600)).To(Succeed())
+			})
+
+			it("rewrites the pid option of the command and its config instead of adding another", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				layerThinConfig := filepath.Join(layersDir, "thin", "cfg.yml")
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`bundle exec thin -C %s --pid /tmp/thin.pid -p "${PORT:-3000}" --log /dev/stdout start`, layerThinConfig)}))
+
+				config, err := thin.ParseThinConfig(layerThinConfig)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config).To(Equal(thin.ThinConfig{"pid": "/tmp/thin.pid"}))
+			})
+		})
+	})
+
+	context("when the thin config logs to a file", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte("log: log/thin.log\ntimeout: 30\n"), 0600)).To(Succeed())
+		})
+
+		it("rewrites the log option to stdout in a copy of the config", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			layerThinConfig := filepath.Join(layersDir, "thin", "thin.yml")
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, layerThinConfig)}))
+
+			config, err := thin.ParseThinConfig(layerThinConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(thin.ThinConfig{"log": "/dev/stdout", "timeout": 30}))
+
+			Expect(buffer.String()).To(ContainSubstring("Redirecting thin logs to stdout"))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("%s: log log/thin.log -> /dev/stdout", filepath.Join(workingDir, "thin.yml"))))
+		})
+
+		context("when the thin config is a per-environment directory", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "thin.yml"))).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "config", "thin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "production.yml"), []byte("log: /var/log/thin.log\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "staging.yml"), []byte("timeout: 30\n"), 0600)).To(Succeed())
+				Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", "config/thin")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+			})
+
+			it("rewrites the directory into the layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				layerThinConfigDir := filepath.Join(layersDir, "thin", "thin")
+				Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring(fmt.Sprintf(`thin_config="%s/${thin_env}.yml"`, layerThinConfigDir)))
+
+				config, err := thin.ParseThinConfig(filepath.Join(layerThinConfigDir, "production.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config).To(Equal(thin.ThinConfig{"log": "/dev/stdout"}))
+
+				config, err = thin.ParseThinConfig(filepath.Join(layerThinConfigDir, "staging.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config).To(Equal(thin.ThinConfig{"timeout": 30}))
+			})
+		})
+
+		context("when the BP_THIN_ALLOW_FILE_LOGS environment variable is true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_ALLOW_FILE_LOGS", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_ALLOW_FILE_LOGS")).To(Succeed())
+			})
+
+			it("keeps logging to the file", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "thin.yml"))}))
+
+				Expect(buffer.String()).To(ContainSubstring("WARNING: keeping thin file logging, since BP_THIN_ALLOW_FILE_LOGS is true"))
+			})
+		})
+
+		context("when the thin command of the Procfile web entry is adopted", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -C cfg.yml\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "cfg.yml"), []byte("log: log/thin.log\n"), 0600)).To(Succeed())
+			})
+
+			it("rewrites the config of the command instead of the default one", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				layerThinConfig := filepath.Join(layersDir, "thin", "cfg.yml")
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, layerThinConfig)}))
+
+				config, err := thin.ParseThinConfig(layerThinConfig)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config).To(Equal(thin.ThinConfig{"log": "/dev/stdout"}))
+
+				Expect(filepath.Join(layersDir, "thin", "thin.yml")).NotTo(BeAnExistingFile())
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("%s: log log/thin.log -> /dev/stdout", filepath.Join(workingDir, "cfg.yml"))))
+			})
+
+			context("when the command passes a log file", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start --log log/thin.log\n"), 0600)).To(Succeed())
+				})
+
+				it("rewrites the log option of the command to stdout", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", fmt.Sprintf(`bundle exec thin -C %s --log /dev/stdout -p "${PORT:-3000}" start`, filepath.Join(layersDir, "thin", "thin.yml"))}))
+
+					Expect(buffer.String()).To(ContainSubstring("thin command: --log log/thin.log -> /dev/stdout"))
+				})
+			})
+		})
+	})
+
+	context("when the buildpack declares SBOM formats", func() {
+		var gemPath string
+
+		it.Before(func() {
+			buildContext.BuildpackInfo.SBOMFormats = []string{sbom.CycloneDXFormat, sbom.SPDXFormat, sbom.SyftFormat}
+
+			lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+				Gems: map[string]string{
+					"daemons":      "1.4.1",
+					"eventmachine": "1.2.7",
+					"rack":         "2.2.6.2",
+					"thin":         "1.8.1",
+				},
+			}
+
+			var err error
+			gemPath, err = os.MkdirTemp("", "gem-path")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(gemPath, "specifications"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(gemPath, "specifications", "thin-1.8.1.gemspec"), []byte(`Gem::Specification.new do |s|
+  s.name = "thin".freeze
+  s.version = "1.8.1"
+  s.licenses = ["GPL-2.0+".freeze, "Ruby".freeze]
+end
+`), 0600)).To(Succeed())
+
+			Expect(writeSharedObject(filepath.Join(gemPath, "gems", "eventmachine-1.2.7", "lib", "rubyeventmachine.so"), elf.EM_X86_64)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(gemPath, "gems", "daemons-1.4.1"), os.ModePerm)).To(Succeed())
+
+			Expect(os.Setenv("GEM_PATH", gemPath)).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_ARCH", "amd64")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("GEM_PATH")).To(Succeed())
+			Expect(os.Unsetenv("CNB_TARGET_ARCH")).To(Succeed())
+			Expect(os.RemoveAll(gemPath)).To(Succeed())
+		})
+
+		it("generates an SBOM of thin and the gems it runs on", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			formats := result.Layers[0].SBOM.Formats()
+			Expect(formats).To(HaveLen(3))
+
+			var extensions []string
+			for _, format := range formats {
+				extensions = append(extensions, format.Extension)
+			}
+			Expect(extensions).To(Equal([]string{"cdx.json", "spdx.json", "syft.json"}))
+
+			content, err := io.ReadAll(formats[2].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"purl":"pkg:gem/thin@1.8.1"`))
+			Expect(string(content)).To(ContainSubstring(`"purl":"pkg:gem/eventmachine@1.2.7"`))
+			Expect(string(content)).To(ContainSubstring(`"purl":"pkg:gem/daemons@1.4.1"`))
+			Expect(string(content)).NotTo(ContainSubstring("pkg:gem/rack"))
+			Expect(string(content)).To(ContainSubstring(`"value":"GPL-2.0+"`))
+			Expect(string(content)).To(ContainSubstring(`"value":"Ruby"`))
+
+			Expect(buffer.String()).To(ContainSubstring("Generating SBOM"))
+		})
+
+		context("when the BP_THIN_SBOM_FORMATS environment variable is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_SBOM_FORMATS", "spdx, application/vnd.cyclonedx+json")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_SBOM_FORMATS")).To(Succeed())
+			})
+
+			it("generates the selected SBOM formats", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				formats := result.Layers[0].SBOM.Formats()
+				Expect(formats).To(HaveLen(2))
+				Expect(formats[0].Extension).To(Equal("spdx.json"))
+				Expect(formats[1].Extension).To(Equal("cdx.json"))
+			})
+
+			context("when a selected format is not declared by the buildpack", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_SBOM_FORMATS", "spdx,text")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_SBOM_FORMATS contains "text", which is not one of the SBOM formats of the buildpack: application/vnd.cyclonedx+json, application/spdx+json, application/vnd.syft+json`)))
+				})
+			})
+		})
+	})
+
+	context("when an advisory database is bound", func() {
+		var dbPath string
+
+		it.Before(func() {
+			var err error
+			dbPath, err = os.MkdirTemp("", "ruby-advisory-db")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(dbPath, "gems", "rack"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dbPath, "gems", "rack", "CVE-2022-30122.yml"), []byte(`---
+gem: rack
+cve: 2022-30122
+title: Denial of Service Vulnerability in Rack Multipart Parsing
+cvss_v3: 7.5
+patched_versions:
+  - "~> 2.0.9, >= 2.0.9.1"
+  - "~> 2.1.4, >= 2.1.4.1"
+  - ">= 2.2.3.1"
+`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dbPath, "gems", "rack", "CVE-2023-27539.yml"), []byte(`---
+gem: rack
+cve: 2023-27539
+title: Possible Denial of Service Vulnerability in Rack's header parsing
+cvss_v3: 5.3
+patched_versions:
+  - ">= 2.2.6.4"
+`), 0600)).To(Succeed())
+
+			bindingResolver.ResolveCall.Returns.BindingSlice = []servicebindings.Binding{
+				{Name: "advisories", Type: "ruby-advisory-db", Path: dbPath},
+			}
+
+			lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+				Gems: map[string]string{
+					"rack": "2.2.6.2",
+					"thin": "1.8.1",
+				},
+			}
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(dbPath)).To(Succeed())
+		})
+
+		it("warns about advisories below the failure severity", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(bindingResolver.ResolveCall.Receives.Typ).To(Equal("ruby-advisory-db"))
+			Expect(bindingResolver.ResolveCall.Receives.PlatformDir).To(Equal("some-platform-path"))
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Checking thin, eventmachine, rack, daemons against the advisory database at %s", dbPath)))
+			Expect(buffer.String()).To(ContainSubstring("CVE-2023-27539 (WARNING: medium): rack 2.2.6.2: Possible Denial of Service Vulnerability in Rack's header parsing"))
+			Expect(buffer.String()).To(ContainSubstring("Patched versions: >= 2.2.6.4"))
+			Expect(buffer.String()).NotTo(ContainSubstring("CVE-2022-30122"))
+		})
+
+		context("when the failure severity is lowered", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_ADVISORY_FAIL_SEVERITY", "medium")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_ADVISORY_FAIL_SEVERITY")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage("found advisories of medium severity or higher: CVE-2023-27539")))
+
+				Expect(buffer.String()).To(ContainSubstring("CVE-2023-27539 (medium): rack 2.2.6.2"))
+			})
+		})
+
+		context("when the locked version has an advisory of the failure severity", func() {
+			it.Before(func() {
+				lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+					Gems: map[string]string{
+						"rack": "2.2.3",
+					},
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage("found advisories of high severity or higher: CVE-2022-30122")))
+
+				Expect(buffer.String()).To(ContainSubstring("CVE-2022-30122 (high): rack 2.2.3: Denial of Service Vulnerability in Rack Multipart Parsing"))
+				Expect(buffer.String()).To(ContainSubstring("Patched versions: ~> 2.0.9, >= 2.0.9.1; ~> 2.1.4, >= 2.1.4.1; >= 2.2.3.1"))
+			})
+		})
+
+		context("when the BP_THIN_ADVISORY_DB environment variable is set", func() {
+			it.Before(func() {
+				bindingResolver.ResolveCall.Returns.BindingSlice = nil
+				Expect(os.Setenv("BP_THIN_ADVISORY_DB", dbPath)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_ADVISORY_DB")).To(Succeed())
+			})
+
+			it("checks against the database at that path", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(bindingResolver.ResolveCall.CallCount).To(Equal(0))
+				Expect(buffer.String()).To(ContainSubstring("CVE-2023-27539 (WARNING: medium)"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when there are several advisory database bindings", func() {
+				it.Before(func() {
+					bindingResolver.ResolveCall.Returns.BindingSlice = append(bindingResolver.ResolveCall.Returns.BindingSlice, servicebindings.Binding{Path: dbPath})
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("found 2 ruby-advisory-db bindings, but expected at most 1")))
+				})
+			})
+
+			context("when the failure severity is unknown", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_ADVISORY_FAIL_SEVERITY", "severe")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_ADVISORY_FAIL_SEVERITY")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_ADVISORY_FAIL_SEVERITY must be one of none, low, medium, high, critical, got "severe"`)))
+				})
+			})
+
+			context("when the binding resolver fails", func() {
+				it.Before(func() {
+					bindingResolver.ResolveCall.Returns.Error = errors.New("failed to resolve bindings")
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError("failed to resolve bindings"))
+				})
+			})
+		})
+	})
+
+	context("when the locked versions are incompatible", func() {
+		it.Before(func() {
+			lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+				Gems: map[string]string{
+					"eventmachine": "1.2.5",
+					"rack":         "3.0.8",
+					"thin":         "1.8.2",
+				},
+				RubyVersion: "3.2.2p53",
+			}
+		})
+
+		it("returns an error", func() {
+			_, err := build(buildContext)
+			Expect(err).To(MatchError(packit.Fail.WithMessage("thin 1.x does not support rack 3 or later (thin 1.8.2 with rack 3.0.8): upgrade thin to 2.0 or later, or lock rack below 3; eventmachine 1.2.6 and earlier do not compile on Ruby 3 or later (eventmachine 1.2.5 with ruby 3.2.2): upgrade eventmachine to 1.2.7 or later, or use Ruby 2.7")))
+		})
+
+		context("when the Ruby version is not declared", func() {
+			it.Before(func() {
+				lockfileParser.ParseCall.Returns.Lockfile.RubyVersion = ""
+			})
+
+			it("only checks the gems", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage("thin 1.x does not support rack 3 or later (thin 1.8.2 with rack 3.0.8): upgrade thin to 2.0 or later, or lock rack below 3")))
+			})
+		})
+	})
+
+	context("when eventmachine is locked and installed in GEM_PATH", func() {
+		var gemPath string
+
+		it.Before(func() {
+			lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+				Gems: map[string]string{
+					"eventmachine": "1.2.7",
+					"thin":         "1.8.1",
+				},
+			}
+
+			var err error
+			gemPath, err = os.MkdirTemp("", "gem-path")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(writeSharedObject(filepath.Join(gemPath, "gems", "eventmachine-1.2.7", "lib", "rubyeventmachine.so"), elf.EM_X86_64)).To(Succeed())
+
+			Expect(os.Setenv("GEM_PATH", gemPath)).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_ARCH", "amd64")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("GEM_PATH")).To(Succeed())
+			Expect(os.Unsetenv("CNB_TARGET_ARCH")).To(Succeed())
+			Expect(os.RemoveAll(gemPath)).To(Succeed())
+		})
+
+		it("accepts the native extension for the target architecture", func() {
+			_, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		context("when the gem is installed in the bundle path instead", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(gemPath, "gems"))).To(Succeed())
+				Expect(writeSharedObject(filepath.Join(workingDir, "vendor", "bundle", "ruby", "3.3.0", "gems", "eventmachine-1.2.7", "lib", "rubyeventmachine.so"), elf.EM_X86_64)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, ".bundle"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, ".bundle", "config"), []byte("---\nBUNDLE_PATH: \"vendor/bundle\"\n"), 0600)).To(Succeed())
+
+				build = thin.Build(lockfileParser, bindingResolver, scribe.NewEmitter(buffer).WithLevel("DEBUG"))
+			})
+
+			it("accepts the native extension for the target architecture", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring("Found the eventmachine native extension for amd64 at " + filepath.Join(workingDir, "vendor", "bundle", "ruby", "3.3.0", "gems", "eventmachine-1.2.7", "lib", "rubyeventmachine.so")))
+			})
+		})
+
+		context("when the gem is not installed", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(gemPath, "gems"))).To(Succeed())
+			})
+
+			it("warns that the native extension cannot be checked", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("WARNING: eventmachine 1.2.7 is locked but was not found in %s, so its native extension cannot be checked", gemPath)))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the native extension was built for another architecture", func() {
+				it.Before(func() {
+					Expect(os.Setenv("CNB_TARGET_ARCH", "arm64")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("eventmachine 1.2.7 has no native extension (rubyeventmachine.so) built for arm64, found: %s (amd64): make sure the build image provides a C++ compiler and the OpenSSL headers so that bundle install can compile it, and that the gem is not installed from another platform", filepath.Join(gemPath, "gems", "eventmachine-1.2.7", "lib", "rubyeventmachine.so"))))
+				})
+			})
+
+			context("when the native extension is missing", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(gemPath, "gems", "eventmachine-1.2.7", "lib", "rubyeventmachine.so"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("eventmachine 1.2.7 has no native extension (rubyeventmachine.so) built for amd64, found: none")))
+				})
+			})
+		})
+	})
+
+	context("when the thin configuration is labelled", func() {
+		it.Before(func() {
+			lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+				Gems: map[string]string{
+					"thin": "1.8.2",
+				},
+			}
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "config.ru"), nil, 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte("socket: /tmp/thin.sock\n"), 0600)).To(Succeed())
+		})
+
+		it("labels the image with the thin version, listener, config, rackup and launcher", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Labels).To(Equal(map[string]string{
+				"io.paketo.thin.config":   filepath.Join(workingDir, "thin.yml"),
+				"io.paketo.thin.launcher": "bundle-exec",
+				"io.paketo.thin.rackup":   filepath.Join(workingDir, "config.ru"),
+				"io.paketo.thin.socket":   "/tmp/thin.sock",
+				"io.paketo.thin.version":  "1.8.2",
+			}))
+		})
+
+		context("when the thin config is selected per environment", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "config", "thin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "production.yml"), []byte("port: 8080\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "staging.yml"), []byte("port: 9090\n"), 0600)).To(Succeed())
+				Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", "config/thin")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+			})
+
+			it("labels the listener of the default environment", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Labels).To(HaveKeyWithValue("io.paketo.thin.config", filepath.Join(workingDir, "config", "thin")))
+				Expect(result.Launch.Labels).To(HaveKeyWithValue("io.paketo.thin.port", "8080"))
+				Expect(result.Launch.Labels).NotTo(HaveKey("io.paketo.thin.socket"))
+			})
+		})
+
+		context("when the thin command of the Procfile web entry is adopted", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin -R app.ru -p 4000 start\n"), 0600)).To(Succeed())
+				Expect(os.Remove(filepath.Join(workingDir, "thin.yml"))).To(Succeed())
+				Expect(os.Setenv("BP_THIN_LAUNCHER", "bundler-setup")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_LAUNCHER")).To(Succeed())
+			})
+
+			it("labels the options of the Procfile command", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Labels).To(Equal(map[string]string{
+					"io.paketo.thin.launcher": "bundler-setup",
+					"io.paketo.thin.port":     "4000",
+					"io.paketo.thin.rackup":   filepath.Join(workingDir, "app.ru"),
+					"io.paketo.thin.version":  "1.8.2",
+				}))
+			})
+		})
+	})
+
+	context("when BP_THIN_STATS_PATH is set", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "config.ru"), nil, 0600)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_STATS_PATH", "/stats")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_STATS_PATH")).To(Succeed())
+		})
+
+		it("serves thin stats through a rackup wrapper protected with the binding credentials at launch", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{
+				"-c",
+				fmt.Sprintf(`bundle exec thin -R %s -p "${PORT:-3000}" start`, filepath.Join(layersDir, "thin", "config.ru")),
+			}))
+			Expect(result.Launch.Labels).To(HaveKeyWithValue("io.paketo.thin.rackup", filepath.Join(layersDir, "thin", "config.ru")))
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "thin", "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(fmt.Sprintf("app = Rack::Builder.parse_file(%q)", filepath.Join(workingDir, "config.ru"))))
+			Expect(string(content)).To(ContainSubstring(`app = Thin::Stats::Adapter.new(app, "/stats")`))
+			Expect(string(content)).To(ContainSubstring(`binding_root = ENV["SERVICE_BINDING_ROOT"].to_s`))
+
+			Expect(bindingResolver.ResolveCall.Receives.Typ).NotTo(Equal("thin-stats"))
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Wrapping %s in a generated rackup file", filepath.Join(workingDir, "config.ru"))))
+			Expect(buffer.String()).To(ContainSubstring("Serving thin stats at /stats with the credentials of the thin-stats service binding at launch"))
+		})
+
+		context("when the thin command of the Procfile web entry is adopted", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin -R app.ru start\n"), 0600)).To(Succeed())
+			})
+
+			it("wraps the rackup file of the Procfile command", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{
+					"-c",
+					fmt.Sprintf(`bundle exec thin -R %s -p "${PORT:-3000}" start`, filepath.Join(layersDir, "thin", "config.ru")),
+				}))
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "thin", "config.ru"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(fmt.Sprintf("app = Rack::Builder.parse_file(%q)", filepath.Join(workingDir, "app.ru"))))
+			})
+		})
+
+		context("when BP_THIN_STATS_ALLOW_UNAUTHENTICATED is true", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_STATS_ALLOW_UNAUTHENTICATED", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_STATS_ALLOW_UNAUTHENTICATED")).To(Succeed())
+			})
+
+			it("serves thin stats without authentication when there is no binding at launch", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "thin", "config.ru"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`warn "WARNING: thin stats are served without authentication, since there is no thin-stats service binding"`))
+
+				Expect(buffer.String()).To(ContainSubstring("WARNING: thin stats at /stats are served without authentication unless a thin-stats service binding is provided at launch"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when BP_THIN_STATS_PATH is not a URL path", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_STATS_PATH", "stats")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_STATS_PATH must be a URL path such as /stats, got "stats"`)))
+				})
+			})
+
+			context("when the app has no config.ru", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "config.ru"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("the app has no config.ru for BP_THIN_STATS_PATH to wrap")))
+				})
+			})
+		})
+	})
+
+	context("when BP_THIN_HEALTH_PATH is set", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "config.ru"), nil, 0600)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_HEALTH_PATH", "/healthz")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_HEALTH_PATH")).To(Succeed())
+		})
+
+		it("serves a health check through a rackup wrapper", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{
+				"-c",
+				fmt.Sprintf(`bundle exec thin -R %s -p "${PORT:-3000}" start`, filepath.Join(layersDir, "thin", "config.ru")),
+			}))
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "thin", "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(fmt.Sprintf("app = Rack::Builder.parse_file(%q)", filepath.Join(workingDir, "config.ru"))))
+			Expect(string(content)).To(ContainSubstring(`env["PATH_INFO"] == "/healthz"`))
+			Expect(string(content)).NotTo(ContainSubstring("Thin::Stats::Adapter"))
+
+			Expect(bindingResolver.ResolveCall.Receives.Typ).To(Equal("ruby-advisory-db"))
+			Expect(buffer.String()).To(ContainSubstring("Serving a health check at /healthz"))
+		})
+
+		context("failure cases", func() {
+			context("when BP_THIN_HEALTH_PATH is not a URL path", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_HEALTH_PATH", "/")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_HEALTH_PATH must be a URL path such as /healthz, got "/"`)))
+				})
+			})
+
+			context("when BP_THIN_HEALTH_PATH and BP_THIN_STATS_PATH are the same", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_STATS_PATH", "/healthz")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_STATS_PATH")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_HEALTH_PATH and BP_THIN_STATS_PATH must differ, both are "/healthz"`)))
+				})
+			})
+
+			context("when the app has no config.ru", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "config.ru"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("the app has no config.ru for BP_THIN_HEALTH_PATH to wrap")))
+				})
+			})
+		})
+	})
+
+	context("when BP_THIN_GZIP and BP_THIN_STATIC_DIRS are set", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "config.ru"), nil, 0600)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "public"), os.ModePerm)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_GZIP", "true")).To(Succeed())
+			Expect(os.Setenv("BP_THIN_STATIC_DIRS", "public")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_GZIP")).To(Succeed())
+			Expect(os.Unsetenv("BP_THIN_STATIC_DIRS")).To(Succeed())
+		})
+
+		it("compresses responses and serves the static files through a rackup wrapper", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{
+				"-c",
+				fmt.Sprintf(`bundle exec thin -R %s -p "${PORT:-3000}" start`, filepath.Join(layersDir, "thin", "config.ru")),
+			}))
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "thin", "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring("app = Rack::Deflater.new(app)"))
+			Expect(string(content)).To(ContainSubstring(`header_rules: [[:all, { "cache-control" => "public, max-age=3600" }]]`))
+			Expect(string(content)).To(ContainSubstring(fmt.Sprintf(`app = serve_static.call(app, %q)`, filepath.Join(workingDir, "public"))))
+
+			Expect(buffer.String()).To(ContainSubstring("Compressing responses with Rack::Deflater"))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Serving static files from %s (max-age 3600)", filepath.Join(workingDir, "public"))))
+		})
+
+		context("when BP_THIN_STATIC_MAX_AGE is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_STATIC_MAX_AGE", "86400")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_STATIC_MAX_AGE")).To(Succeed())
+			})
+
+			it("uses it as the max-age of the static files", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "thin", "config.ru"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`"cache-control" => "public, max-age=86400"`))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when a static directory does not exist", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_STATIC_DIRS", "public,assets")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_STATIC_DIRS lists "assets", which does not exist in the app`)))
+				})
+			})
+
+			context("when a static directory is a file", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_STATIC_DIRS", "config.ru")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_STATIC_DIRS lists "config.ru", which is not a directory`)))
+				})
+			})
+
+			context("when a static directory is outside of the app", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_STATIC_DIRS", "../public")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_STATIC_DIRS must list directories within the app, got "../public"`)))
+				})
+			})
+
+			context("when BP_THIN_STATIC_MAX_AGE is not a number of seconds", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_STATIC_MAX_AGE", "1h")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_STATIC_MAX_AGE")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_STATIC_MAX_AGE must be a number of seconds, got "1h"`)))
+				})
+			})
+
+			context("when the app has no config.ru", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "config.ru"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("the app has no config.ru for BP_THIN_GZIP and BP_THIN_STATIC_DIRS to wrap")))
+				})
+			})
+		})
+	})
+
+	context("when a thin.yml file exists in the working directory", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte{}, os.ModePerm)).To(Succeed())
+		})
+
+		it("returns a result with that file provided to the thin start command", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch).To(Equal(packit.LaunchMetadata{
+				Processes: []packit.Process{
+					{
+						Type:    "web",
+						Command: "bash",
+						Args: []string{
+							"-c",
+							fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "thin.yml")),
+						},
+						Default: true,
+						Direct:  true,
+					},
+				},
+				Labels: map[string]string{
+					"io.paketo.thin.config":   filepath.Join(workingDir, "thin.yml"),
+					"io.paketo.thin.launcher": "bundle-exec",
+					"io.paketo.thin.port":     "3000",
+				},
+			}))
+		})
+	})
+
+	context("when both thin.yml and config.ru files exist in the working directory", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte{}, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "config.ru"), []byte{}, os.ModePerm)).To(Succeed())
+		})
+
+		it("returns a result with both files provided to the thin start command", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch).To(Equal(packit.LaunchMetadata{
+				Processes: []packit.Process{
+					{
+						Type:    "web",
+						Command: "bash",
+						Args: []string{
+							"-c",
+							fmt.Sprintf(`bundle exec thin -C %s -R %s -p "${PORT:-3000}" start`,
+								filepath.Join(workingDir, "thin.yml"),
+								filepath.Join(workingDir, "config.ru"),
+							),
+						},
+						Default: true,
+						Direct:  true,
+					},
+				},
+				Labels: map[string]string{
+					"io.paketo.thin.config":   filepath.Join(workingDir, "thin.yml"),
+					"io.paketo.thin.launcher": "bundle-exec",
+					"io.paketo.thin.port":     "3000",
+					"io.paketo.thin.rackup":   filepath.Join(workingDir, "config.ru"),
+				},
+			}))
+		})
+	})
+
+	context("when the BP_THIN_CONFIG_LOCATION environment variable points to a valid file", func() {
+		it.Before(func() {
+			thinConfigFilepath := filepath.Join(workingDir, "some-thin-config.yml")
+			Expect(os.WriteFile(thinConfigFilepath, []byte{}, os.ModePerm)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", thinConfigFilepath)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+		})
+
+		it("returns a result with that file provided to the thin start command", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch).To(Equal(packit.LaunchMetadata{
+				Processes: []packit.Process{
+					{
+						Type:    "web",
+						Command: "bash",
+						Args: []string{
+							"-c",
+							fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "some-thin-config.yml")),
+						},
+						Default: true,
+						Direct:  true,
+					},
+				},
+				Labels: map[string]string{
+					"io.paketo.thin.config":   filepath.Join(workingDir, "some-thin-config.yml"),
+					"io.paketo.thin.launcher": "bundle-exec",
+					"io.paketo.thin.port":     "3000",
+				},
+			}))
+		})
+
+		context("when the BP_THIN_CONFIG_LOCATION environment variable is a relative path", func() {
+			it.Before(func() {
+				relativeFilepath := filepath.Join("some-dir", "some-thin-config.yml")
+				Expect(os.MkdirAll(filepath.Join(workingDir, "some-dir"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "some-dir", "some-thin-config.yml"), []byte{}, os.ModePerm)).To(Succeed())
+				Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", relativeFilepath)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+			})
+
+			it("returns a result with that file relative to the working directory", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch).To(Equal(packit.LaunchMetadata{
+					Processes: []packit.Process{
+						{
+							Type:    "web",
+							Command: "bash",
+							Args: []string{
+								"-c",
+								fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "some-dir", "some-thin-config.yml")),
+							},
+							Default: true,
+							Direct:  true,
+						},
+					},
+					Labels: map[string]string{
+						"io.paketo.thin.config":   filepath.Join(workingDir, "some-dir", "some-thin-config.yml"),
+						"io.paketo.thin.launcher": "bundle-exec",
+						"io.paketo.thin.port":     "3000",
+					},
+				}))
+			})
+		})
+	})
+
+	context("when the BP_THIN_CONFIG_LOCATION environment variable points to a directory", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "config", "thin"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "production.yml"), []byte{}, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "staging.yml"), []byte{}, os.ModePerm)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", filepath.Join("config", "thin"))).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+		})
+
+		it("selects the config file for the launch environment at startup", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{
+					Type:    "web",
+					Command: "bash",
+					Args: []string{
+						"-c",
+						strings.Join([]string{
+							`thin_env="${THIN_ENV:-${RACK_ENV:-production}}"`,
+							`if [[ "${thin_env}" == */* ]]; then echo "thin environment '${thin_env}' must not contain '/'" >&2; exit 1; fi`,
+							fmt.Sprintf(`thin_config="%s/${thin_env}.yml"`, filepath.Join(workingDir, "config", "thin")),
+							`if [[ ! -f "${thin_config}" ]]; then echo "thin config for environment '${thin_env}' does not exist at ${thin_config}" >&2; exit 1; fi`,
+							`bundle exec thin -C "${thin_config}" -p "${PORT:-3000}" start`,
+						}, "; "),
+					},
+					Default: true,
+					Direct:  true,
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Selecting thin config from %s at launch", filepath.Join(workingDir, "config", "thin"))))
+			Expect(buffer.String()).To(ContainSubstring("Available environments: production, staging"))
+			Expect(buffer.String()).To(ContainSubstring("Default environment: production"))
+		})
+
+		context("when BP_THIN_CONFIG_DEFAULT_ENV is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_CONFIG_DEFAULT_ENV", "staging")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_CONFIG_DEFAULT_ENV")).To(Succeed())
+			})
+
+			it("falls back to that environment", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args[1]).To(HavePrefix(`thin_env="${THIN_ENV:-${RACK_ENV:-staging}}"; `))
+				Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("RACK_ENV.default", "staging"))
+			})
+
+			context("when the app is a Rails app", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "config", "application.rb"), []byte{}, os.ModePerm)).To(Succeed())
+
+					lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+						Gems: map[string]string{
+							"rails": "7.1.3",
+						},
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`rails environment "staging" is not production: set BP_THIN_ALLOW_NON_PRODUCTION_ENV=true to allow it`)))
+				})
+
+				context("when BP_THIN_ALLOW_NON_PRODUCTION_ENV is true", func() {
+					it.Before(func() {
+						Expect(os.Setenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV", "true")).To(Succeed())
+					})
+
+					it.After(func() {
+						Expect(os.Unsetenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV")).To(Succeed())
+					})
+
+					it("starts thin in that environment", func() {
+						result, err := build(buildContext)
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring(`-e "${RAILS_ENV:-${RACK_ENV:-staging}}"`))
+						Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("RAILS_ENV.default", "staging"))
+					})
+				})
+			})
+		})
+
+		context("when the app is a Rails app with a non-production RAILS_ENV", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "application.rb"), []byte{}, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "production.yml"), []byte("port: 8080\n"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "staging.yml"), []byte("port: 9090\n"), os.ModePerm)).To(Succeed())
+				Expect(os.Setenv("RAILS_ENV", "staging")).To(Succeed())
+				Expect(os.Setenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV", "true")).To(Succeed())
+
+				lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+					Gems: map[string]string{
+						"rails": "7.1.3",
+					},
+				}
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("RAILS_ENV")).To(Succeed())
+				Expect(os.Unsetenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV")).To(Succeed())
+			})
+
+			it("selects the config file of the rails environment by default", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args[1]).To(HavePrefix(`thin_env="${THIN_ENV:-${RACK_ENV:-staging}}"; `))
+				Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("RACK_ENV.default", "staging"))
+				Expect(result.Launch.Labels).To(HaveKeyWithValue("io.paketo.thin.port", "9090"))
+				Expect(buffer.String()).To(ContainSubstring("Default environment: staging"))
+			})
+
+			context("when the directory does not contain a config file for the rails environment", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "config", "thin", "staging.yml"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("thin config directory %s does not contain a config file for the default environment: staging.yml", filepath.Join(workingDir, "config", "thin"))))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the directory does not contain a config file for the default environment", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "config", "thin", "production.yml"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("thin config directory %s does not contain a config file for the default environment: production.yml", filepath.Join(workingDir, "config", "thin"))))
+				})
+			})
+
+			context("when the directory does not contain any config files", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "config", "thin", "production.yml"))).To(Succeed())
+					Expect(os.Remove(filepath.Join(workingDir, "config", "thin", "staging.yml"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("thin config directory does not contain any .yml files: %s", filepath.Join(workingDir, "config", "thin"))))
+				})
+			})
+		})
+	})
+
+	context("when the BP_THIN_CONFIG_LOCATION environment variable lists several files", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "base.yml"), []byte("port: 3000\ntimeout: 30\n"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "override.yml"), []byte("port: 8080\n"), os.ModePerm)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", strings.Join([]string{"base.yml", "override.yml"}, string(os.PathListSeparator)))).To(Succeed())
+
+			build = thin.Build(lockfileParser, bindingResolver, scribe.NewEmitter(buffer).WithLevel("DEBUG"))
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+		})
+
+		it("merges them into a thin config file in a launch layer", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(1))
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("thin"))
+			Expect(layer.Path).To(Equal(filepath.Join(layersDir, "thin")))
+			Expect(layer.Launch).To(BeTrue())
+			Expect(layer.Build).To(BeFalse())
+			Expect(layer.Cache).To(BeFalse())
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "thin", "thin.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("port: 8080\ntimeout: 30\n"))
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{
+				"-c",
+				fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(layersDir, "thin", "thin.yml")),
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("Merging thin config files"))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("port: 8080 (from %s)", filepath.Join(workingDir, "override.yml"))))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("timeout: 30 (from %s)", filepath.Join(workingDir, "base.yml"))))
+		})
+
+		context("failure cases", func() {
+			context("when one of the files does not exist", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "override.yml"))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("thin config file does not exist at: %s", filepath.Join(workingDir, "override.yml"))))
+				})
+			})
+
+			context("when one of the entries is empty", func() {
+				it("returns an error", func() {
+					for _, location := range []string{":base.yml", "base.yml:", "base.yml::override.yml"} {
+						Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", location)).To(Succeed())
+
+						_, err := build(buildContext)
+						Expect(err).To(MatchError(packit.Fail.WithMessage("BP_THIN_CONFIG_LOCATION contains an empty entry: %q", location)), location)
+					}
+				})
+			})
+
+			context("when one of the entries is a directory", func() {
+				it.Before(func() {
+					Expect(os.MkdirAll(filepath.Join(workingDir, "config", "thin"), os.ModePerm)).To(Succeed())
+					Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", strings.Join([]string{"base.yml", filepath.Join("config", "thin")}, string(os.PathListSeparator)))).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("thin config directories cannot be merged with other thin config files: %s", filepath.Join(workingDir, "config", "thin"))))
+				})
+			})
+
+			context("when one of the files cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "override.yml"), []byte("port: [8080"), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse thin config file")))
+				})
+			})
+		})
+	})
+
+	context("when the BP_THIN_CONFIG_LOCATION environment variable points outside of the working directory", func() {
+		var externalDir string
+
+		it.Before(func() {
+			var err error
+			externalDir, err = os.MkdirTemp("", "external")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(externalDir, "some-thin-config.yml"), []byte("port: 8080\n"), os.ModePerm)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", filepath.Join(externalDir, "some-thin-config.yml"))).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+			Expect(os.RemoveAll(externalDir)).To(Succeed())
+		})
+
+		it("copies the file into a launch layer and provides the copy to the thin start command", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(1))
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("thin"))
+			Expect(layer.Launch).To(BeTrue())
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "thin", "some-thin-config.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("port: 8080\n"))
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{
+				"-c",
+				fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(layersDir, "thin", "some-thin-config.yml")),
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("Copying thin config into the thin layer"))
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("WARNING: %s is outside of the application directory and will not be present in the app image", filepath.Join(externalDir, "some-thin-config.yml"))))
+		})
+
+		context("when it points to a directory of per-environment config files", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(externalDir, "thin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(externalDir, "thin", "production.yml"), []byte{}, os.ModePerm)).To(Succeed())
+				Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", filepath.Join(externalDir, "thin"))).To(Succeed())
+			})
+
+			it("copies the directory into a launch layer", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers).To(HaveLen(1))
+				Expect(filepath.Join(layersDir, "thin", "thin", "production.yml")).To(BeARegularFile())
+
+				Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring(fmt.Sprintf(`thin_config="%s/${thin_env}.yml"`, filepath.Join(layersDir, "thin", "thin"))))
+			})
+		})
+	})
+
+	context("when the BP_THIN_CONFIG environment variable is set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_THIN_CONFIG", "port: 8080\ntimeout: 30\n")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_CONFIG")).To(Succeed())
+		})
+
+		it("writes the config into a launch layer and provides it to the thin start command", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(1))
+			layer := result.Layers[0]
+			Expect(layer.Name).To(Equal("thin"))
+			Expect(layer.Launch).To(BeTrue())
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "thin", "thin.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("port: 8080\ntimeout: 30\n"))
+
+			Expect(result.Launch.Processes[0].Args).To(Equal([]string{
+				"-c",
+				fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(layersDir, "thin", "thin.yml")),
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("Writing thin config from BP_THIN_CONFIG into the thin layer"))
+		})
+
+		context("when the config is given as JSON", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_CONFIG", `{"port": 8080, "tag": "some-tag"}`)).To(Succeed())
+			})
+
+			it("writes the config as YAML", func() {
+				_, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(filepath.Join(layersDir, "thin", "thin.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("port: 8080\ntag: some-tag\n"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the config is neither YAML nor JSON", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_CONFIG", `{"port": 8080`)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(ContainSubstring("BP_THIN_CONFIG is not a valid YAML or JSON thin config:")))
+				})
+			})
+
+			context("when the BP_THIN_CONFIG_LOCATION environment variable is also set", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte{}, os.ModePerm)).To(Succeed())
+					Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", "thin.yml")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage("BP_THIN_CONFIG and BP_THIN_CONFIG_LOCATION cannot both be set")))
+				})
+			})
+		})
+	})
+
+	context("when both the BP_THIN_CONFIG_LOCATION env var is set and a thin.yml file is present", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte{}, os.ModePerm)).To(Succeed())
+
+			envVarThinConfigFilepath := filepath.Join(workingDir, "some-thin-config.yml")
+			Expect(os.WriteFile(envVarThinConfigFilepath, []byte{}, os.ModePerm)).To(Succeed())
+			Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", envVarThinConfigFilepath)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+		})
+
+		it("prioritizes the environment variable", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch).To(Equal(packit.LaunchMetadata{
+				Processes: []packit.Process{
+					{
+						Type:    "web",
+						Command: "bash",
+						Args: []string{
+							"-c",
+							fmt.Sprintf(`bundle exec thin -C %s -p "${PORT:-3000}" start`, filepath.Join(workingDir, "some-thin-config.yml")),
+						},
+						Default: true,
+						Direct:  true,
+					},
+				},
+				Labels: map[string]string{
+					"io.paketo.thin.config":   filepath.Join(workingDir, "some-thin-config.yml"),
+					"io.paketo.thin.launcher": "bundle-exec",
+					"io.paketo.thin.port":     "3000",
+				},
+			}))
+		})
+	})
+
+	context("when the app is a Rails app", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "config"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "config", "application.rb"), []byte{}, os.ModePerm)).To(Succeed())
+
+			lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+				Gems: map[string]string{
+					"rails": "7.1.3",
+					"thin":  "1.8.2",
+				},
+			}
+		})
+
+		it("starts thin with the rails adapter in the production environment", func() {
+			result, err := build(buildContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.Processes).To(Equal([]packit.Process{
+				{
+					Type:    "web",
+					Command: "bash",
+					Args:    []string{"-c", `bundle exec thin -A rails -e "${RAILS_ENV:-${RACK_ENV:-production}}" -p "${PORT:-3000}" start`},
+					Default: true,
+					Direct:  true,
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("No config.ru found, starting thin with the rails adapter"))
+		})
+
+		context("when a config.ru file exists", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "config.ru"), []byte{}, os.ModePerm)).To(Succeed())
+			})
+
+			it("starts thin with the rackup file", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{
+					"-c",
+					fmt.Sprintf(`bundle exec thin -R %s -e "${RAILS_ENV:-${RACK_ENV:-production}}" -p "${PORT:-3000}" start`, filepath.Join(workingDir, "config.ru")),
+				}))
+			})
+		})
+
+		context("when only railties is locked", func() {
+			it.Before(func() {
+				lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+					Gems: map[string]string{
+						"railties": "7.1.3",
+					},
+				}
+			})
+
+			it("starts thin with the rails adapter", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring("-A rails"))
+			})
+		})
+
+		context("when rails is not locked", func() {
+			it.Before(func() {
+				lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{}
+			})
+
+			it("does not treat the app as a Rails app", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", `bundle exec thin -p "${PORT:-3000}" start`}))
+			})
+		})
+
+		context("when RAILS_ENV is set to a non-production environment", func() {
+			it.Before(func() {
+				Expect(os.Setenv("RAILS_ENV", "staging")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("RAILS_ENV")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`rails environment "staging" is not production: set BP_THIN_ALLOW_NON_PRODUCTION_ENV=true to allow it`)))
+			})
+
+			context("when BP_THIN_ALLOW_NON_PRODUCTION_ENV is true", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV", "true")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV")).To(Succeed())
+				})
+
+				it("defaults thin to that environment", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring(`-e "${RAILS_ENV:-${RACK_ENV:-staging}}"`))
+					Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("RAILS_ENV.default", "staging"))
+					Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("RACK_ENV.default", "staging"))
+				})
+			})
+		})
+
+		context("when BP_THIN_DEFAULT_RAILS_ENV or BP_THIN_DEFAULT_RACK_ENV is set to a non-production environment", func() {
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_DEFAULT_RAILS_ENV")).To(Succeed())
+				Expect(os.Unsetenv("BP_THIN_DEFAULT_RACK_ENV")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				for _, name := range []string{"BP_THIN_DEFAULT_RAILS_ENV", "BP_THIN_DEFAULT_RACK_ENV"} {
+					Expect(os.Setenv(name, "development")).To(Succeed())
+
+					_, err := build(buildContext)
+					Expect(err).To(MatchError(packit.Fail.WithMessage(`rails environment "development" is not production: set BP_THIN_ALLOW_NON_PRODUCTION_ENV=true to allow it`)), name)
+
+					Expect(os.Unsetenv(name)).To(Succeed())
+				}
+			})
+
+			context("when BP_THIN_ALLOW_NON_PRODUCTION_ENV is true", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_DEFAULT_RAILS_ENV", "development")).To(Succeed())
+					Expect(os.Setenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV", "true")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV")).To(Succeed())
+				})
+
+				it("defaults RAILS_ENV to that environment", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Layers[0].LaunchEnv).To(HaveKeyWithValue("RAILS_ENV.default", "development"))
+				})
+			})
+
+			context("when the launch default is removed instead", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_DEFAULT_RAILS_ENV", "false")).To(Succeed())
+				})
+
+				it("starts thin in the production environment", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring(`-e "${RAILS_ENV:-${RACK_ENV:-production}}"`))
+					Expect(result.Layers[0].LaunchEnv).NotTo(HaveKey("RAILS_ENV.default"))
+				})
+			})
+		})
+
+		context("when the adopted thin command of the Procfile sets a non-production environment", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -e development -p $PORT\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`rails environment "development" is not production: set BP_THIN_ALLOW_NON_PRODUCTION_ENV=true to allow it`)))
+			})
+
+			context("when BP_THIN_ALLOW_NON_PRODUCTION_ENV is true", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV", "true")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_THIN_ALLOW_NON_PRODUCTION_ENV")).To(Succeed())
+				})
+
+				it("adopts the thin command", func() {
+					result, err := build(buildContext)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(result.Launch.Processes[0].Args).To(Equal([]string{"-c", `bundle exec thin -e development -p "${PORT:-3000}" start`}))
+				})
+			})
+		})
+
+		context("when RACK_ENV is set to production", func() {
+			it.Before(func() {
+				Expect(os.Setenv("RACK_ENV", "production")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("RACK_ENV")).To(Succeed())
+			})
+
+			it("starts thin in the production environment", func() {
+				result, err := build(buildContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Launch.Processes[0].Args[1]).To(ContainSubstring(`-e "${RAILS_ENV:-${RACK_ENV:-production}}"`))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when BP_THIN_STRICT_LOCKFILE is true and the Gemfile.lock does not list thin", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte("gem 'thin', '~> 1.8'\n"), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile.lock"), []byte("GEM\n  specs:\n    rack (2.2.8)\n\nDEPENDENCIES\n  rack\n"), 0600)).To(Succeed())
+
+				lockfileParser.ParseCall.Returns.Lockfile = thin.GemfileLock{
+					Gems:         map[string]string{"rack": "2.2.8"},
+					GemLines:     map[string]int{"rack": 3},
+					Dependencies: map[string]thin.GemfileLockDependency{"rack": {Line: 6}},
+				}
+				Expect(os.Setenv("BP_THIN_STRICT_LOCKFILE", "true")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_STRICT_LOCKFILE")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage("the Gemfile and Gemfile.lock disagree about thin: Gemfile:1 lists thin but the DEPENDENCIES of Gemfile.lock do not: run bundle install and commit the updated Gemfile.lock")))
+			})
+		})
+
+		context("when BP_THIN_STRICT_LOCKFILE is not a boolean", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_STRICT_LOCKFILE", "sometimes")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_STRICT_LOCKFILE")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_STRICT_LOCKFILE must be true or false, got "sometimes"`)))
+			})
+		})
+
+		context("when the thin config sets a user for thin to switch to", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "thin.yml"), []byte("user: www-data\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`thin config %s sets "user", but thin cannot switch to another user in the app image: remove it, the app runs as the run image user`, filepath.Join(workingDir, "thin.yml"))))
+			})
+		})
+
+		context("when a per-environment thin config sets a group for thin to switch to", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "config", "thin"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "production.yml"), nil, 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "config", "thin", "staging.yml"), []byte("group: www-data\n"), 0600)).To(Succeed())
+				Expect(os.Setenv("BP_THIN_CONFIG_LOCATION", "config/thin")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_CONFIG_LOCATION")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`thin config %s sets "group", but thin cannot switch to another group in the app image: remove it, the app runs as the run image user`, filepath.Join(workingDir, "config", "thin", "staging.yml"))))
+			})
+		})
+
+		context("when the Procfile web entry runs thin with shell syntax that cannot be adopted", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start -p $PORT && echo done\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`failed to adopt the thin command of the Procfile web entry "bundle exec thin start -p $PORT && echo done": shell syntax "&" in "bundle exec thin start -p $PORT && echo done" is not supported`)))
+			})
+		})
+
+		context("when the Procfile web entry passes an unknown option to thin", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "Procfile"), []byte("web: bundle exec thin start --workers 4\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`failed to adopt the thin command of the Procfile web entry "bundle exec thin start --workers 4": unknown thin option --workers`)))
+			})
+		})
+
+		context("when the BP_THIN_PROCESS_TYPE environment variable is not a valid process type", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_PROCESS_TYPE", "thin server")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_PROCESS_TYPE")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_PROCESS_TYPE "thin server" is not a valid process type: it may only contain letters, numbers, '.', '_' and '-'`)))
+			})
+		})
+
+		context("when the BP_THIN_PROCESS_TYPE environment variable conflicts with BP_THIN_PROCESSES", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_PROCESS_TYPE", "console")).To(Succeed())
+				Expect(os.Setenv("BP_THIN_PROCESSES", "console")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_PROCESS_TYPE")).To(Succeed())
+				Expect(os.Unsetenv("BP_THIN_PROCESSES")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_PROCESS_TYPE "console" conflicts with a process type listed in BP_THIN_PROCESSES`)))
+			})
+		})
+
+		context("when the BP_THIN_DEFAULT_PROCESS environment variable is not a boolean", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_DEFAULT_PROCESS", "sometimes")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_DEFAULT_PROCESS")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_DEFAULT_PROCESS must be true or false, got "sometimes"`)))
+			})
+		})
+
+		context("when the BP_THIN_PROCESSES environment variable lists an unknown process type", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_PROCESSES", "console,worker")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_PROCESSES")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_PROCESSES contains an unknown process type "worker": it must list console, rake or web-debug`)))
+			})
+		})
+
+		context("when the BP_THIN_LAUNCHER environment variable is binstub and there is no binstub", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_LAUNCHER", "binstub")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_LAUNCHER")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage("BP_THIN_LAUNCHER is binstub, but there is no thin binstub at %s", filepath.Join(workingDir, "bin", "thin"))))
+			})
+		})
+
+		context("when the BP_THIN_LAUNCHER environment variable is not a known launcher", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_THIN_LAUNCHER", "foreman")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_THIN_LAUNCHER")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError(packit.Fail.WithMessage(`BP_THIN_LAUNCHER must be one of bundle-exec, binstub or bundler-setup, got "foreman"`)))
+			})
+		})
+
+		context("when the Gemfile.lock cannot be parsed", func() {
+			it.Before(func() {
+				lockfileParser.ParseCall.Returns.Err = errors.New("failed to parse Gemfile.lock")
+			})
+
+			it("returns an error", func() {
+				_, err := build(buildContext)
+				Expect(err).To(MatchError("failed to parse Gemfile.lock"))
+			})
+		})
+
 		context("when there is an error determining if the default thin config file exists", func() {
 			it.Before(func() {
 				envVarThinConfigFilepath := filepath.Join(workingDir, "some-thin-config.yml")
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LibraryPaths returns the glob patterns of the locations where run images
+// are known to provide the jemalloc shared library, in order of preference:
+// the multiarch directory of the given architecture (as named by
+// runtime.GOARCH) comes first, followed by the generic library directories.
+// Multiarch directories of other architectures are never searched.
+func LibraryPaths(goarch string) []string {
+	var paths []string
+
+	switch goarch {
+	case "amd64":
+		paths = append(paths, "/usr/lib/x86_64-linux-gnu/libjemalloc.so*")
+	case "arm64":
+		paths = append(paths, "/usr/lib/aarch64-linux-gnu/libjemalloc.so*")
+	}
+
+	return append(paths,
+		"/usr/lib64/libjemalloc.so*",
+		"/usr/lib/libjemalloc.so*",
+		"/usr/local/lib/libjemalloc.so*",
+	)
+}
+
+// Run looks for the jemalloc shared library at the given locations and, if it
+// is found, writes an LD_PRELOAD environment variable that loads it ahead of
+// any existing preloads to the output as exec.d TOML. The outcome is reported
+// on the logs writer either way.
+func Run(paths []string, ldPreload string, logs, output io.Writer) error {
+	for _, pattern := range paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("failed to search for jemalloc: %w", err)
+		}
+
+		if len(matches) == 0 {
+			continue
+		}
+
+		library := matches[0]
+		fmt.Fprintf(logs, "Preloading jemalloc from %s\n", library)
+
+		preload := strings.TrimSpace(strings.Join([]string{library, ldPreload}, " "))
+		err = toml.NewEncoder(output).Encode(map[string]string{
+			"LD_PRELOAD": preload,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write LD_PRELOAD: %w", err)
+		}
+
+		return nil
+	}
+
+	fmt.Fprintln(logs, "jemalloc was not found in the run image, using the default memory allocator")
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package internal_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin/cmd/preload-jemalloc/internal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRun(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		libDir string
+		logs   *bytes.Buffer
+		output *bytes.Buffer
+	)
+
+	it.Before(func() {
+		var err error
+		libDir, err = os.MkdirTemp("", "lib")
+		Expect(err).NotTo(HaveOccurred())
+
+		logs = bytes.NewBuffer(nil)
+		output = bytes.NewBuffer(nil)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(libDir)).To(Succeed())
+	})
+
+	context("when jemalloc is present", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(libDir, "other"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(libDir, "other", "libjemalloc.so.2"), []byte{}, 0600)).To(Succeed())
+		})
+
+		it("preloads it", func() {
+			err := internal.Run([]string{
+				filepath.Join(libDir, "missing", "libjemalloc.so*"),
+				filepath.Join(libDir, "other", "libjemalloc.so*"),
+			}, "", logs, output)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(output.String()).To(Equal(`LD_PRELOAD = "` + filepath.Join(libDir, "other", "libjemalloc.so.2") + `"` + "\n"))
+			Expect(logs.String()).To(ContainSubstring("Preloading jemalloc from " + filepath.Join(libDir, "other", "libjemalloc.so.2")))
+		})
+
+		context("when LD_PRELOAD is already set", func() {
+			it("preloads jemalloc ahead of the existing libraries", func() {
+				err := internal.Run([]string{filepath.Join(libDir, "other", "libjemalloc.so*")}, "/some/libother.so", logs, output)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(output.String()).To(Equal(`LD_PRELOAD = "` + filepath.Join(libDir, "other", "libjemalloc.so.2") + ` /some/libother.so"` + "\n"))
+			})
+		})
+	})
+
+	context("when jemalloc is not present", func() {
+		it("leaves LD_PRELOAD unchanged", func() {
+			err := internal.Run([]string{filepath.Join(libDir, "libjemalloc.so*")}, "", logs, output)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(output.String()).To(BeEmpty())
+			Expect(logs.String()).To(ContainSubstring("jemalloc was not found in the run image, using the default memory allocator"))
+		})
+	})
+
+	context("LibraryPaths", func() {
+		it("searches the multiarch directory of the given architecture first", func() {
+			Expect(internal.LibraryPaths("amd64")).To(Equal([]string{
+				"/usr/lib/x86_64-linux-gnu/libjemalloc.so*",
+				"/usr/lib64/libjemalloc.so*",
+				"/usr/lib/libjemalloc.so*",
+				"/usr/local/lib/libjemalloc.so*",
+			}))
+
+			Expect(internal.LibraryPaths("arm64")).To(Equal([]string{
+				"/usr/lib/aarch64-linux-gnu/libjemalloc.so*",
+				"/usr/lib64/libjemalloc.so*",
+				"/usr/lib/libjemalloc.so*",
+				"/usr/local/lib/libjemalloc.so*",
+			}))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when a search pattern is malformed", func() {
+			it("returns an error", func() {
+				err := internal.Run([]string{"["}, "", logs, output)
+				Expect(err).To(MatchError(ContainSubstring("failed to search for jemalloc:")))
+			})
+		})
+	})
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/paketo-buildpacks/thin/cmd/preload-jemalloc/internal"
+)
+
+func main() {
+	err := internal.Run(internal.LibraryPaths(runtime.GOARCH), os.Getenv("LD_PRELOAD"), os.Stdout, os.NewFile(3, "/dev/fd/3"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
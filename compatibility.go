@@ -0,0 +1,91 @@
+package thin
+
+import (
+	"fmt"
+)
+
+// compatibilityRule declares that versions of a gem that satisfy requirement
+// do not work with versions of another gem (or of Ruby, named "ruby") that
+// satisfy otherRequirement.
+type compatibilityRule struct {
+	gem              string
+	requirement      string
+	other            string
+	otherRequirement string
+
+	// summary names the incompatible versions, e.g. "thin 1.x does not support
+	// rack 3 or later".
+	summary string
+
+	// remediation tells how to resolve the incompatibility.
+	remediation string
+}
+
+// compatibilityMatrix holds the known incompatibilities between thin, rack,
+// eventmachine and Ruby.
+var compatibilityMatrix = []compatibilityRule{
+	{
+		gem: "thin", requirement: "< 2", other: "rack", otherRequirement: ">= 3",
+		summary:     "thin 1.x does not support rack 3 or later",
+		remediation: "upgrade thin to 2.0 or later, or lock rack below 3",
+	},
+	{
+		gem: "thin", requirement: "< 1.8", other: "ruby", otherRequirement: ">= 3",
+		summary:     "thin 1.7 and earlier do not support Ruby 3 or later",
+		remediation: "upgrade thin to 1.8 or later, or use Ruby 2.7",
+	},
+	{
+		gem: "eventmachine", requirement: "< 1.2.7", other: "ruby", otherRequirement: ">= 3",
+		summary:     "eventmachine 1.2.6 and earlier do not compile on Ruby 3 or later",
+		remediation: "upgrade eventmachine to 1.2.7 or later, or use Ruby 2.7",
+	},
+	{
+		gem: "eventmachine", requirement: "< 1.2", other: "ruby", otherRequirement: ">= 2.4",
+		summary:     "eventmachine 1.1 and earlier do not compile on Ruby 2.4 or later",
+		remediation: "upgrade eventmachine to 1.2.7 or later",
+	},
+}
+
+// CheckCompatibility evaluates the locked versions of thin, rack and
+// eventmachine, and the lowest Ruby version the app allows, against the
+// compatibility matrix. It returns a description of every incompatibility it
+// finds. Rules about Ruby are skipped when the Ruby version is unknown.
+func CheckCompatibility(lockfile GemfileLock, rubyVersion string) ([]string, error) {
+	versions := map[string]string{}
+	for name, version := range lockfile.Gems {
+		versions[name] = version
+	}
+
+	if rubyVersion != "" {
+		versions["ruby"] = rubyVersion
+	}
+
+	var incompatibilities []string
+	for _, rule := range compatibilityMatrix {
+		version, ok := versions[rule.gem]
+		if !ok {
+			continue
+		}
+
+		otherVersion, ok := versions[rule.other]
+		if !ok {
+			continue
+		}
+
+		matches, err := GemRequirementSatisfied(version, rule.requirement)
+		if err != nil {
+			return nil, err
+		}
+
+		otherMatches, err := GemRequirementSatisfied(otherVersion, rule.otherRequirement)
+		if err != nil {
+			return nil, err
+		}
+
+		if matches && otherMatches {
+			incompatibilities = append(incompatibilities, fmt.Sprintf("%s (%s %s with %s %s): %s", rule.summary, rule.gem, version, rule.other, otherVersion, rule.remediation))
+		}
+	}
+
+	return incompatibilities, nil
+}
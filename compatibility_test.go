@@ -0,0 +1,63 @@
+package thin_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testCompatibility(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("CheckCompatibility", func() {
+		it("accepts compatible versions", func() {
+			incompatibilities, err := thin.CheckCompatibility(thin.GemfileLock{
+				Gems: map[string]string{
+					"eventmachine": "1.2.7",
+					"rack":         "3.0.8",
+					"thin":         "2.0.1",
+				},
+			}, "3.3.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(incompatibilities).To(BeEmpty())
+
+			incompatibilities, err = thin.CheckCompatibility(thin.GemfileLock{
+				Gems: map[string]string{
+					"eventmachine": "1.2.7",
+					"rack":         "2.2.8",
+					"thin":         "1.8.2",
+				},
+			}, "3.1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(incompatibilities).To(BeEmpty())
+		})
+
+		it("reports incompatible versions", func() {
+			incompatibilities, err := thin.CheckCompatibility(thin.GemfileLock{
+				Gems: map[string]string{
+					"eventmachine": "1.0.9.1",
+					"thin":         "1.7.2",
+				},
+			}, "3.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(incompatibilities).To(Equal([]string{
+				"thin 1.7 and earlier do not support Ruby 3 or later (thin 1.7.2 with ruby 3.0.0): upgrade thin to 1.8 or later, or use Ruby 2.7",
+				"eventmachine 1.2.6 and earlier do not compile on Ruby 3 or later (eventmachine 1.0.9.1 with ruby 3.0.0): upgrade eventmachine to 1.2.7 or later, or use Ruby 2.7",
+				"eventmachine 1.1 and earlier do not compile on Ruby 2.4 or later (eventmachine 1.0.9.1 with ruby 3.0.0): upgrade eventmachine to 1.2.7 or later",
+			}))
+		})
+
+		it("skips the Ruby rules when the Ruby version is unknown", func() {
+			incompatibilities, err := thin.CheckCompatibility(thin.GemfileLock{
+				Gems: map[string]string{
+					"thin": "1.7.2",
+				},
+			}, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(incompatibilities).To(BeEmpty())
+		})
+	})
+}
@@ -0,0 +1,73 @@
+package thin
+
+import (
+	"debug/elf"
+	"fmt"
+	"path/filepath"
+
+	"github.com/paketo-buildpacks/packit/v2/fs"
+)
+
+// elfMachines maps the ELF machine of a shared object to its architecture.
+var elfMachines = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_386:     "386",
+	elf.EM_ARM:     "arm",
+	elf.EM_PPC64:   "ppc64le",
+	elf.EM_S390:    "s390x",
+}
+
+// FindEventMachineExtensions returns the rubyeventmachine.so native
+// extensions of the given version of the eventmachine gem, as installed in
+// the given gem paths (in the gem's lib directory or in the extensions
+// directory), mapped to their architecture. It also reports whether the gem
+// is installed at all.
+func FindEventMachineExtensions(gemPaths []string, version string) (map[string]string, bool, error) {
+	extensions := map[string]string{}
+	installed := false
+
+	for _, gemPath := range gemPaths {
+		exists, err := fs.Exists(filepath.Join(gemPath, "gems", "eventmachine-"+version))
+		if err != nil {
+			return nil, false, err
+		}
+
+		if exists {
+			installed = true
+		}
+
+		var candidates []string
+		for _, pattern := range []string{
+			filepath.Join(gemPath, "gems", "eventmachine-"+version, "lib", "rubyeventmachine.so"),
+			filepath.Join(gemPath, "extensions", "*", "*", "eventmachine-"+version, "rubyeventmachine.so"),
+		} {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, false, err
+			}
+
+			candidates = append(candidates, matches...)
+		}
+
+		for _, candidate := range candidates {
+			file, err := elf.Open(candidate)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to inspect eventmachine native extension %s: %w", candidate, err)
+			}
+
+			arch, ok := elfMachines[file.Machine]
+			if !ok {
+				arch = file.Machine.String()
+			}
+			extensions[candidate] = arch
+
+			err = file.Close()
+			if err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	return extensions, installed, nil
+}
@@ -0,0 +1,102 @@
+package thin_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+// writeSharedObject writes a minimal ELF shared object for the given machine
+// to path.
+func writeSharedObject(path string, machine elf.Machine) error {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	header := elf.Header64{
+		Type:    uint16(elf.ET_DYN),
+		Machine: uint16(machine),
+		Version: uint32(elf.EV_CURRENT),
+		Ehsize:  64,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	buffer := bytes.NewBuffer(nil)
+	err = binary.Write(buffer, binary.LittleEndian, header)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+func testEventMachine(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		gemPath string
+	)
+
+	it.Before(func() {
+		var err error
+		gemPath, err = os.MkdirTemp("", "gem-path")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(gemPath, "gems", "eventmachine-1.2.7"), os.ModePerm)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(gemPath)).To(Succeed())
+	})
+
+	context("FindEventMachineExtensions", func() {
+		it.Before(func() {
+			Expect(writeSharedObject(filepath.Join(gemPath, "gems", "eventmachine-1.2.7", "lib", "rubyeventmachine.so"), elf.EM_AARCH64)).To(Succeed())
+			Expect(writeSharedObject(filepath.Join(gemPath, "extensions", "x86_64-linux", "3.3.0", "eventmachine-1.2.7", "rubyeventmachine.so"), elf.EM_X86_64)).To(Succeed())
+		})
+
+		it("returns the native extensions of the installed gem with their architecture", func() {
+			extensions, installed, err := thin.FindEventMachineExtensions([]string{"/does/not/exist", gemPath}, "1.2.7")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installed).To(BeTrue())
+			Expect(extensions).To(Equal(map[string]string{
+				filepath.Join(gemPath, "gems", "eventmachine-1.2.7", "lib", "rubyeventmachine.so"):                         "arm64",
+				filepath.Join(gemPath, "extensions", "x86_64-linux", "3.3.0", "eventmachine-1.2.7", "rubyeventmachine.so"): "amd64",
+			}))
+		})
+
+		context("when the gem is not installed", func() {
+			it("reports it", func() {
+				extensions, installed, err := thin.FindEventMachineExtensions([]string{gemPath}, "1.2.6")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(installed).To(BeFalse())
+				Expect(extensions).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when a native extension is not a shared object", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(gemPath, "gems", "eventmachine-1.2.7", "lib", "rubyeventmachine.so"), []byte("not an ELF file"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, _, err := thin.FindEventMachineExtensions([]string{gemPath}, "1.2.7")
+					Expect(err).To(MatchError(ContainSubstring("failed to inspect eventmachine native extension")))
+				})
+			})
+		})
+	})
+}
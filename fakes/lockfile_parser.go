@@ -0,0 +1,33 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/paketo-buildpacks/thin"
+)
+
+type LockfileParser struct {
+	ParseCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			Path string
+		}
+		Returns struct {
+			Lockfile thin.GemfileLock
+			Err      error
+		}
+		Stub func(string) (thin.GemfileLock, error)
+	}
+}
+
+func (f *LockfileParser) Parse(param1 string) (thin.GemfileLock, error) {
+	f.ParseCall.Lock()
+	defer f.ParseCall.Unlock()
+	f.ParseCall.CallCount++
+	f.ParseCall.Receives.Path = param1
+	if f.ParseCall.Stub != nil {
+		return f.ParseCall.Stub(param1)
+	}
+	return f.ParseCall.Returns.Lockfile, f.ParseCall.Returns.Err
+}
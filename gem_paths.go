@@ -0,0 +1,153 @@
+package thin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// GemPaths returns the directories that the gems of the app in workingDir
+// may be installed in: GEM_PATH and GEM_HOME, followed by the gem
+// directories of the bundle path (<BUNDLE_PATH>/ruby/<version>) that Bundler
+// installs the gems of the app in, e.g. the path set by the bundle-install
+// buildpack or vendor/bundle. Directories that do not exist are left out of
+// the bundle path.
+func GemPaths(workingDir string) ([]string, error) {
+	var gemPaths []string
+	for _, path := range filepath.SplitList(os.Getenv("GEM_PATH")) {
+		if path != "" && !slices.Contains(gemPaths, path) {
+			gemPaths = append(gemPaths, path)
+		}
+	}
+
+	if gemHome := os.Getenv("GEM_HOME"); gemHome != "" && !slices.Contains(gemPaths, gemHome) {
+		gemPaths = append(gemPaths, gemHome)
+	}
+
+	bundlePath, err := BundlePath(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if bundlePath == "" {
+		return gemPaths, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(bundlePath, "ruby", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, match := range matches {
+		if !slices.Contains(gemPaths, match) {
+			gemPaths = append(gemPaths, match)
+		}
+	}
+
+	return gemPaths, nil
+}
+
+// BundlePath returns the BUNDLE_PATH setting of the app in workingDir the way
+// Bundler resolves it: from the environment, else from the app config
+// ($BUNDLE_APP_CONFIG/config or .bundle/config), else from the user config
+// ($BUNDLE_USER_CONFIG or ~/.bundle/config). Relative paths are resolved
+// against workingDir, and in deployment mode the path defaults to
+// vendor/bundle. It returns an empty path if the bundle is installed in the
+// system gems.
+func BundlePath(workingDir string) (string, error) {
+	appConfigDir := os.Getenv("BUNDLE_APP_CONFIG")
+	if appConfigDir == "" {
+		appConfigDir = ".bundle"
+	}
+	if !filepath.IsAbs(appConfigDir) {
+		appConfigDir = filepath.Join(workingDir, appConfigDir)
+	}
+
+	userConfig := os.Getenv("BUNDLE_USER_CONFIG")
+	if userConfig == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			userConfig = filepath.Join(home, ".bundle", "config")
+		}
+	}
+
+	settings := []map[string]string{bundleEnvironment()}
+	for _, path := range []string{filepath.Join(appConfigDir, "config"), userConfig} {
+		if path == "" {
+			continue
+		}
+
+		config, err := parseBundleConfig(path)
+		if err != nil {
+			return "", err
+		}
+
+		settings = append(settings, config)
+	}
+
+	var path, deployment string
+	for _, setting := range settings {
+		if value, ok := setting["BUNDLE_PATH"]; ok && path == "" {
+			path = value
+		}
+
+		if value, ok := setting["BUNDLE_DEPLOYMENT"]; ok && deployment == "" {
+			deployment = value
+		}
+	}
+
+	if path == "" && deployment == "true" {
+		path = filepath.Join("vendor", "bundle")
+	}
+
+	if path == "" {
+		return "", nil
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+
+	return path, nil
+}
+
+// bundleEnvironment returns the Bundler settings of the environment.
+func bundleEnvironment() map[string]string {
+	settings := map[string]string{}
+	for _, name := range []string{"BUNDLE_PATH", "BUNDLE_DEPLOYMENT"} {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			settings[name] = value
+		}
+	}
+
+	return settings
+}
+
+// parseBundleConfig reads the Bundler config file at the given path. A missing
+// file results in no settings.
+func parseBundleConfig(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+
+		return nil, fmt.Errorf("failed to read Bundler config file: %w", err)
+	}
+
+	config := map[string]interface{}{}
+	err = yaml.Unmarshal(content, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Bundler config file %s: %w", path, err)
+	}
+
+	settings := map[string]string{}
+	for name, value := range config {
+		settings[name] = fmt.Sprint(value)
+	}
+
+	return settings, nil
+}
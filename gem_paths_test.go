@@ -0,0 +1,121 @@
+package thin_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testGemPaths(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, ".bundle"), os.ModePerm)).To(Succeed())
+		Expect(os.Setenv("BUNDLE_USER_CONFIG", filepath.Join(workingDir, "user-config"))).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("BUNDLE_USER_CONFIG")).To(Succeed())
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("GemPaths", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "vendor", "bundle", "ruby", "3.3.0"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".bundle", "config"), []byte("---\nBUNDLE_PATH: \"vendor/bundle\"\n"), 0600)).To(Succeed())
+
+			Expect(os.Setenv("GEM_PATH", "/gems/a:/gems/b")).To(Succeed())
+			Expect(os.Setenv("GEM_HOME", "/gems/b")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("GEM_PATH")).To(Succeed())
+			Expect(os.Unsetenv("GEM_HOME")).To(Succeed())
+		})
+
+		it("returns GEM_PATH and GEM_HOME followed by the gem directories of the bundle path", func() {
+			gemPaths, err := thin.GemPaths(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gemPaths).To(Equal([]string{
+				"/gems/a",
+				"/gems/b",
+				filepath.Join(workingDir, "vendor", "bundle", "ruby", "3.3.0"),
+			}))
+		})
+	})
+
+	context("BundlePath", func() {
+		it("returns nothing when the bundle is installed in the system gems", func() {
+			bundlePath, err := thin.BundlePath(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bundlePath).To(BeEmpty())
+		})
+
+		it("reads the app config", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".bundle", "config"), []byte("---\nBUNDLE_PATH: \"vendor/bundle\"\n"), 0600)).To(Succeed())
+
+			bundlePath, err := thin.BundlePath(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bundlePath).To(Equal(filepath.Join(workingDir, "vendor", "bundle")))
+		})
+
+		it("reads the user config", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "user-config"), []byte("---\nBUNDLE_PATH: \"/layers/gems\"\n"), 0600)).To(Succeed())
+
+			bundlePath, err := thin.BundlePath(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bundlePath).To(Equal("/layers/gems"))
+		})
+
+		it("defaults to vendor/bundle in deployment mode", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".bundle", "config"), []byte("---\nBUNDLE_DEPLOYMENT: \"true\"\n"), 0600)).To(Succeed())
+
+			bundlePath, err := thin.BundlePath(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bundlePath).To(Equal(filepath.Join(workingDir, "vendor", "bundle")))
+		})
+
+		context("when BUNDLE_PATH is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BUNDLE_PATH", "/layers/bundle")).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, ".bundle", "config"), []byte("---\nBUNDLE_PATH: \"vendor/bundle\"\n"), 0600)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BUNDLE_PATH")).To(Succeed())
+			})
+
+			it("prefers it over the config files", func() {
+				bundlePath, err := thin.BundlePath(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bundlePath).To(Equal("/layers/bundle"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the app config cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, ".bundle", "config"), []byte("BUNDLE_PATH: [\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := thin.BundlePath(workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse Bundler config file")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,106 @@
+package thin
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/anchore/syft/syft/pkg"
+	syftsbom "github.com/anchore/syft/syft/sbom"
+	"github.com/anchore/syft/syft/source"
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+)
+
+// thinGems are the gems that thin runs on, in the order they are listed in the
+// SBOM.
+var thinGems = []string{"thin", "eventmachine", "daemons"}
+
+// GenerateGemSBOM returns an SBOM of the locked versions of the given gems,
+// identified by their gem package URLs. The licenses of a gem are read from its
+// installed gemspec when one is found in the specifications directory of one
+// of the given gem paths. Gems that are not locked are left out.
+func GenerateGemSBOM(lockfile GemfileLock, names []string, gemPaths []string, path string) (sbom.SBOM, error) {
+	collection := pkg.NewCollection()
+	for _, name := range names {
+		version, ok := lockfile.Gems[name]
+		if !ok {
+			continue
+		}
+
+		licenses, err := gemspecLicenses(gemPaths, name, version)
+		if err != nil {
+			return sbom.SBOM{}, err
+		}
+
+		licenseSet := pkg.NewLicenseSet()
+		for _, license := range licenses {
+			licenseSet.Add(pkg.NewLicense(license))
+		}
+
+		gem := pkg.Package{
+			Name:     name,
+			Version:  version,
+			Type:     pkg.GemPkg,
+			Language: pkg.Ruby,
+			Licenses: licenseSet,
+			PURL:     fmt.Sprintf("pkg:gem/%s@%s", name, version),
+		}
+		gem.SetID()
+
+		collection.Add(gem)
+	}
+
+	return sbom.NewSBOM(syftsbom.SBOM{
+		Artifacts: syftsbom.Artifacts{
+			Packages: collection,
+		},
+		Source: source.Description{
+			Metadata: source.DirectoryMetadata{
+				Path: path,
+			},
+		},
+	}), nil
+}
+
+// gemspecLicenses returns the licenses declared in the installed gemspec of the
+// given gem version, or nil if no gemspec is found.
+func gemspecLicenses(gemPaths []string, name, version string) ([]string, error) {
+	for _, gemPath := range gemPaths {
+		file, err := os.Open(filepath.Join(gemPath, "specifications", fmt.Sprintf("%s-%s.gemspec", name, version)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to read gemspec: %w", err)
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				_ = err
+			}
+		}()
+
+		licensesRe := regexp.MustCompile(`^\s*\w+\.licenses?\s*=\s*(.+)$`)
+		quotedRe := regexp.MustCompile(`["']([^"']+)["']`)
+		scanner := bufio.NewScanner(file)
+
+		var licenses []string
+		for scanner.Scan() {
+			if matches := licensesRe.FindStringSubmatch(scanner.Text()); matches != nil {
+				for _, license := range quotedRe.FindAllStringSubmatch(matches[1], -1) {
+					licenses = append(licenses, license[1])
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read gemspec: %w", err)
+		}
+
+		return licenses, nil
+	}
+
+	return nil, nil
+}
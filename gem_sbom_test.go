@@ -0,0 +1,78 @@
+package thin_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/packit/v2/sbom"
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testGemSBOM(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		gemPath  string
+		lockfile thin.GemfileLock
+	)
+
+	it.Before(func() {
+		var err error
+		gemPath, err = os.MkdirTemp("", "gem-path")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(gemPath, "specifications"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(gemPath, "specifications", "daemons-1.4.1.gemspec"), []byte(`Gem::Specification.new do |s|
+  s.name = "daemons".freeze
+  s.license = "MIT".freeze
+end
+`), 0600)).To(Succeed())
+
+		lockfile = thin.GemfileLock{
+			Gems: map[string]string{
+				"daemons":      "1.4.1",
+				"eventmachine": "1.2.7",
+			},
+		}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(gemPath)).To(Succeed())
+	})
+
+	context("GenerateGemSBOM", func() {
+		it("lists the locked gems with their licenses", func() {
+			bom, err := thin.GenerateGemSBOM(lockfile, []string{"thin", "eventmachine", "daemons"}, []string{"/does/not/exist", gemPath}, "/workspace")
+			Expect(err).NotTo(HaveOccurred())
+
+			formatter, err := bom.InFormats(sbom.SyftFormat)
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := io.ReadAll(formatter.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"purl":"pkg:gem/daemons@1.4.1"`))
+			Expect(string(content)).To(ContainSubstring(`"value":"MIT"`))
+			Expect(string(content)).To(ContainSubstring(`"purl":"pkg:gem/eventmachine@1.2.7"`))
+			Expect(string(content)).NotTo(ContainSubstring("pkg:gem/thin"))
+		})
+
+		context("failure cases", func() {
+			context("when a gemspec cannot be read", func() {
+				it.Before(func() {
+					Expect(os.Chmod(filepath.Join(gemPath, "specifications", "daemons-1.4.1.gemspec"), 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := thin.GenerateGemSBOM(lockfile, []string{"daemons"}, []string{gemPath}, "/workspace")
+					Expect(err).To(MatchError(ContainSubstring("failed to read gemspec:")))
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package thin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CompareGemVersions compares two gem versions the way RubyGems does: segment
+// by segment, where numeric segments compare numerically and sort after
+// prerelease segments such as "rc1". It returns -1, 0 or 1.
+func CompareGemVersions(a, b string) int {
+	left := gemVersionSegments(a)
+	right := gemVersionSegments(b)
+
+	for i := 0; i < max(len(left), len(right)); i++ {
+		l, r := "0", "0"
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+
+		ln, lErr := strconv.Atoi(l)
+		rn, rErr := strconv.Atoi(r)
+
+		switch {
+		case lErr == nil && rErr == nil:
+			if ln != rn {
+				if ln < rn {
+					return -1
+				}
+				return 1
+			}
+		case lErr == nil:
+			return 1
+		case rErr == nil:
+			return -1
+		default:
+			if c := strings.Compare(l, r); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+// gemVersionSegments splits a gem version into its segments, ignoring any
+// platform suffix (e.g. "-x86_64-linux"). Letters are split from the digits
+// that follow them, so that "1.0.0.rc1" becomes 1, 0, 0, "rc", 1.
+func gemVersionSegments(version string) []string {
+	version, _, _ = strings.Cut(strings.TrimSpace(version), "-")
+
+	var segments []string
+	for _, part := range strings.Split(version, ".") {
+		start := 0
+		for i := 1; i <= len(part); i++ {
+			if i == len(part) || isDigit(part[i]) != isDigit(part[i-1]) {
+				segments = append(segments, part[start:i])
+				start = i
+			}
+		}
+	}
+
+	return segments
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// GemRequirementSatisfied reports whether the gem version satisfies the given
+// requirement, e.g. ">= 2.2.3" or "~> 2.0.8", or all of the comma-separated
+// requirements of an entry such as ">= 1.0, < 2.0".
+func GemRequirementSatisfied(version, requirement string) (bool, error) {
+	if strings.Contains(requirement, ",") {
+		for _, requirement := range strings.Split(requirement, ",") {
+			satisfied, err := GemRequirementSatisfied(version, requirement)
+			if err != nil || !satisfied {
+				return false, err
+			}
+		}
+
+		return true, nil
+	}
+
+	requirement = strings.TrimSpace(requirement)
+
+	operator := "="
+	for _, candidate := range []string{"~>", ">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(requirement, candidate) {
+			operator = candidate
+			requirement = strings.TrimSpace(strings.TrimPrefix(requirement, candidate))
+			break
+		}
+	}
+
+	if requirement == "" {
+		return false, fmt.Errorf("invalid gem requirement %q", operator)
+	}
+
+	c := CompareGemVersions(version, requirement)
+	switch operator {
+	case "=":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case ">":
+		return c > 0, nil
+	case "<":
+		return c < 0, nil
+	case ">=":
+		return c >= 0, nil
+	case "<=":
+		return c <= 0, nil
+	default:
+		// "~> 2.0.8" allows versions from 2.0.8 up to, but not including, 2.1.
+		segments := strings.Split(requirement, ".")
+		if len(segments) > 1 {
+			segments = segments[:len(segments)-1]
+		}
+
+		last, err := strconv.Atoi(segments[len(segments)-1])
+		if err != nil {
+			return false, fmt.Errorf("invalid gem requirement %q", "~> "+requirement)
+		}
+		segments[len(segments)-1] = strconv.Itoa(last + 1)
+
+		return c >= 0 && CompareGemVersions(version, strings.Join(segments, ".")) < 0, nil
+	}
+}
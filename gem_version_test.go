@@ -0,0 +1,99 @@
+package thin_test
+
+import (
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testGemVersion(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("CompareGemVersions", func() {
+		it("compares gem versions the way RubyGems does", func() {
+			for _, comparison := range []struct {
+				a, b   string
+				result int
+			}{
+				{a: "1.8.2", b: "1.8.2", result: 0},
+				{a: "1.8.1", b: "1.8.2", result: -1},
+				{a: "1.10.0", b: "1.9.0", result: 1},
+				{a: "1.0.0.rc1", b: "1.0.0", result: -1},
+				{a: "1.0.0", b: "1.0.0.rc1", result: 1},
+				{a: "1.0.0.rc1", b: "1.0.0.rc2", result: -1},
+				{a: "1.0.0.beta1", b: "1.0.0.rc1", result: -1},
+				{a: "1.0.0.rc1", b: "0.9.9", result: 1},
+				{a: "1.0", b: "1.0.0", result: 0},
+				{a: "1", b: "1.0.0.0", result: 0},
+				{a: "1.0.0.1", b: "1.0", result: 1},
+				{a: "1.8.2-x86_64-linux", b: "1.8.2", result: 0},
+				{a: "1.8.2-java", b: "1.8.3", result: -1},
+			} {
+				Expect(thin.CompareGemVersions(comparison.a, comparison.b)).To(Equal(comparison.result), comparison.a+" <=> "+comparison.b)
+			}
+		})
+	})
+
+	context("GemRequirementSatisfied", func() {
+		it("checks gem versions against requirements the way RubyGems does", func() {
+			for _, check := range []struct {
+				version, requirement string
+				satisfied            bool
+			}{
+				{version: "1.8.2", requirement: "1.8.2", satisfied: true},
+				{version: "1.8.2", requirement: "= 1.8.2", satisfied: true},
+				{version: "1.8.2", requirement: "= 1.8.2.0", satisfied: true},
+				{version: "1.8.2", requirement: "!= 1.8.2", satisfied: false},
+				{version: "1.8.2", requirement: "> 1.8.1", satisfied: true},
+				{version: "1.8.2", requirement: "< 1.8.2", satisfied: false},
+				{version: "1.8.2", requirement: ">= 1.8.2", satisfied: true},
+				{version: "1.8.2", requirement: "<= 1.8.1", satisfied: false},
+				{version: "1.8.2-x86_64-linux", requirement: ">= 1.8.2", satisfied: true},
+				{version: "1.8.2.rc1", requirement: ">= 1.8.2", satisfied: false},
+				{version: "1.8.2.rc1", requirement: ">= 1.8.2.beta1", satisfied: true},
+
+				{version: "1.0.0", requirement: "~> 1", satisfied: true},
+				{version: "1.9.9", requirement: "~> 1", satisfied: true},
+				{version: "2.0.0", requirement: "~> 1", satisfied: false},
+				{version: "0.9.9", requirement: "~> 1", satisfied: false},
+				{version: "2.2.0", requirement: "~> 2.2", satisfied: true},
+				{version: "2.9.0", requirement: "~> 2.2", satisfied: true},
+				{version: "2.1.9", requirement: "~> 2.2", satisfied: false},
+				{version: "3.0.0", requirement: "~> 2.2", satisfied: false},
+				{version: "2.0.8", requirement: "~> 2.0.8", satisfied: true},
+				{version: "2.0.10", requirement: "~> 2.0.8", satisfied: true},
+				{version: "2.0.7", requirement: "~> 2.0.8", satisfied: false},
+				{version: "2.1.0", requirement: "~> 2.0.8", satisfied: false},
+				{version: "2.1.0.rc1", requirement: "~> 2.0.8", satisfied: true},
+
+				{version: "1.5.0", requirement: ">= 1.0, < 2.0", satisfied: true},
+				{version: "2.0.0", requirement: ">= 1.0, < 2.0", satisfied: false},
+				{version: "0.9.0", requirement: ">= 1.0, < 2.0", satisfied: false},
+				{version: "2.0.9.1", requirement: "~> 2.0.9, >= 2.0.9.1", satisfied: true},
+				{version: "2.0.9", requirement: "~> 2.0.9, >= 2.0.9.1", satisfied: false},
+			} {
+				satisfied, err := thin.GemRequirementSatisfied(check.version, check.requirement)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(satisfied).To(Equal(check.satisfied), check.version+" "+check.requirement)
+			}
+		})
+
+		context("failure cases", func() {
+			it("returns an error for invalid requirements", func() {
+				for requirement, message := range map[string]string{
+					">=":               `invalid gem requirement ">="`,
+					"~> rc1":           `invalid gem requirement "~> rc1"`,
+					"~> 1.x.1":         `invalid gem requirement "~> 1.x.1"`,
+					">= 1.0, <":        `invalid gem requirement "<"`,
+					"~> 1.0, ~> beta1": `invalid gem requirement "~> beta1"`,
+				} {
+					_, err := thin.GemRequirementSatisfied("1.0.0", requirement)
+					Expect(err).To(MatchError(message), requirement)
+				}
+			})
+		})
+	})
+}
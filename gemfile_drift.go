@@ -0,0 +1,202 @@
+package thin
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/fs"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+// gemDeclaration is where and how a Gemfile or a Gemfile.lock declares a gem.
+type gemDeclaration struct {
+	line         int
+	requirements []string
+}
+
+// CheckGemfileDrift compares the way the Gemfile in workingDir and the given
+// Gemfile.lock declare the given gem: whether each of them lists it, whether
+// they agree on its version requirements, and whether the locked version
+// satisfies the requirements of the Gemfile. It returns a description of every
+// mismatch, prefixed with the file and line it was found at. Nothing is
+// reported when either file is missing.
+func CheckGemfileDrift(workingDir string, lockfile GemfileLock, gem string) ([]string, error) {
+	gemfileDeclaration, gemfileExists, err := parseGemfileDeclaration(filepath.Join(workingDir, "Gemfile"), gem)
+	if err != nil {
+		return nil, err
+	}
+
+	lockfileExists, err := fs.Exists(filepath.Join(workingDir, "Gemfile.lock"))
+	if err != nil {
+		return nil, err
+	}
+
+	var dependency, spec *gemDeclaration
+	if listed, ok := lockfile.Dependencies[gem]; ok {
+		dependency = &gemDeclaration{line: listed.Line}
+		for _, requirement := range listed.Requirements {
+			dependency.requirements = append(dependency.requirements, normalizeRequirement(requirement))
+		}
+		slices.Sort(dependency.requirements)
+	}
+
+	if version, ok := lockfile.Gems[gem]; ok {
+		spec = &gemDeclaration{line: lockfile.GemLines[gem], requirements: []string{version}}
+	}
+
+	if !gemfileExists || !lockfileExists {
+		return nil, nil
+	}
+
+	var drifts []string
+	switch {
+	case gemfileDeclaration == nil && dependency == nil:
+		return nil, nil
+
+	case dependency == nil:
+		drifts = append(drifts, fmt.Sprintf("Gemfile:%d lists %s but the DEPENDENCIES of Gemfile.lock do not", gemfileDeclaration.line, gem))
+
+	case gemfileDeclaration == nil:
+		drifts = append(drifts, fmt.Sprintf("Gemfile.lock:%d lists %s as a dependency but the Gemfile does not", dependency.line, gem))
+
+	default:
+		if !slices.Equal(gemfileDeclaration.requirements, dependency.requirements) {
+			drifts = append(drifts, fmt.Sprintf("Gemfile:%d requires %s %s but Gemfile.lock:%d records %s", gemfileDeclaration.line, gem, describeRequirements(gemfileDeclaration.requirements), dependency.line, describeRequirements(dependency.requirements)))
+		}
+	}
+
+	if gemfileDeclaration != nil && spec != nil {
+		for _, requirement := range gemfileDeclaration.requirements {
+			satisfied, err := GemRequirementSatisfied(spec.requirements[0], requirement)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check Gemfile:%d: %w", gemfileDeclaration.line, err)
+			}
+
+			if !satisfied {
+				drifts = append(drifts, fmt.Sprintf("Gemfile.lock:%d locks %s %s, which does not satisfy %s from Gemfile:%d", spec.line, gem, spec.requirements[0], describeRequirements(gemfileDeclaration.requirements), gemfileDeclaration.line))
+				break
+			}
+		}
+	}
+
+	if dependency != nil && spec == nil {
+		drifts = append(drifts, fmt.Sprintf("Gemfile.lock:%d lists %s as a dependency but does not lock a version of it", dependency.line, gem))
+	}
+
+	return drifts, nil
+}
+
+// parseGemfileDeclaration finds the gem directive for the given gem in the
+// Gemfile at path and returns its line and its version requirements. It
+// reports whether the Gemfile exists.
+func parseGemfileDeclaration(path, gem string) (*gemDeclaration, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+
+		return nil, false, fmt.Errorf("failed to parse Gemfile: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			_ = err
+		}
+	}()
+
+	gemRe := regexp.MustCompile(fmt.Sprintf(`^\s*gem\s*\(?\s*["']%s["']\s*(.*)$`, regexp.QuoteMeta(gem)))
+	requirementRe := regexp.MustCompile(`^["']\s*((?:~>|>=|<=|!=|>|<|=)?\s*\d[^"']*)["']$`)
+	commentRe := regexp.MustCompile(`\s+#.*$`)
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		matches := gemRe.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+
+		declaration := gemDeclaration{line: line}
+		for _, argument := range strings.Split(commentRe.ReplaceAllString(matches[1], ""), ",") {
+			if requirement := requirementRe.FindStringSubmatch(strings.TrimSpace(argument)); requirement != nil {
+				declaration.requirements = append(declaration.requirements, normalizeRequirement(requirement[1]))
+			}
+		}
+		slices.Sort(declaration.requirements)
+
+		return &declaration, true, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to parse Gemfile: %w", err)
+	}
+
+	return nil, true, nil
+}
+
+// normalizeRequirement rewrites a version requirement as "<operator>
+// <version>", so that "1.8.1", "=1.8.1" and "= 1.8.1" compare equal.
+func normalizeRequirement(requirement string) string {
+	requirement = strings.TrimSpace(requirement)
+
+	operator := "="
+	for _, candidate := range []string{"~>", ">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(requirement, candidate) {
+			operator = candidate
+			requirement = strings.TrimPrefix(requirement, candidate)
+			break
+		}
+	}
+
+	return operator + " " + strings.TrimSpace(requirement)
+}
+
+func describeRequirements(requirements []string) string {
+	if len(requirements) == 0 {
+		return "without a version requirement"
+	}
+
+	return strconv.Quote(strings.Join(requirements, ", "))
+}
+
+// checkGemfileDrift logs the drift between the way the Gemfile and the
+// Gemfile.lock declare thin. When BP_THIN_STRICT_LOCKFILE is true, it returns
+// an error describing the drift instead.
+func checkGemfileDrift(workingDir string, lockfile GemfileLock, logger scribe.Emitter) error {
+	strict := false
+	if value, ok := os.LookupEnv("BP_THIN_STRICT_LOCKFILE"); ok {
+		var err error
+		strict, err = strconv.ParseBool(value)
+		if err != nil {
+			return packit.Fail.WithMessage("BP_THIN_STRICT_LOCKFILE must be true or false, got %q", value)
+		}
+	}
+
+	drifts, err := CheckGemfileDrift(workingDir, lockfile, "thin")
+	if err != nil {
+		return err
+	}
+
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	if strict {
+		return packit.Fail.WithMessage("the Gemfile and Gemfile.lock disagree about thin: %s: run bundle install and commit the updated Gemfile.lock", strings.Join(drifts, "; "))
+	}
+
+	logger.Process("WARNING: the Gemfile and Gemfile.lock disagree about thin")
+	for _, drift := range drifts {
+		logger.Subprocess(drift)
+	}
+	logger.Subprocess("Run bundle install and commit the updated Gemfile.lock, or set BP_THIN_STRICT_LOCKFILE=true to fail the build")
+	logger.Break()
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package thin_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testGemfileDrift(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+
+		checkGemfileDrift func() ([]string, error)
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		checkGemfileDrift = func() ([]string, error) {
+			lockfile, err := thin.NewGemfileLockParser().Parse(filepath.Join(workingDir, "Gemfile.lock"))
+			Expect(err).NotTo(HaveOccurred())
+
+			return thin.CheckGemfileDrift(workingDir, lockfile, "thin")
+		}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("CheckGemfileDrift", func() {
+		it("reports nothing when the Gemfile and Gemfile.lock agree", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte(`source 'https://rubygems.org'
+
+gem "thin", "~> 1.8", ">= 1.8.1", require: false # the web server
+`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile.lock"), []byte(`GEM
+  remote: https://rubygems.org/
+  specs:
+    thin (1.8.2)
+
+DEPENDENCIES
+  thin (>= 1.8.1, ~> 1.8)
+`), 0600)).To(Succeed())
+
+			drifts, err := checkGemfileDrift()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drifts).To(BeEmpty())
+		})
+
+		it("reports nothing when the Gemfile.lock is missing", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte("gem 'thin'\n"), 0600)).To(Succeed())
+
+			drifts, err := checkGemfileDrift()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drifts).To(BeEmpty())
+		})
+
+		it("reports a gem that only the Gemfile.lock lists", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte("gem 'rack'\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile.lock"), []byte(`GEM
+  specs:
+    rack (2.2.8)
+    thin (1.8.2)
+
+DEPENDENCIES
+  rack
+  thin
+`), 0600)).To(Succeed())
+
+			drifts, err := checkGemfileDrift()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drifts).To(Equal([]string{
+				"Gemfile.lock:8 lists thin as a dependency but the Gemfile does not",
+			}))
+		})
+
+		it("reports mismatched requirements and a locked version that does not satisfy them", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte("source 'https://rubygems.org'\n  gem 'thin', '~> 2.0'\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile.lock"), []byte(`GEM
+  specs:
+    thin (1.8.2)
+
+DEPENDENCIES
+  thin (~> 1.8)
+`), 0600)).To(Succeed())
+
+			drifts, err := checkGemfileDrift()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drifts).To(Equal([]string{
+				`Gemfile:2 requires thin "~> 2.0" but Gemfile.lock:6 records "~> 1.8"`,
+				`Gemfile.lock:3 locks thin 1.8.2, which does not satisfy "~> 2.0" from Gemfile:2`,
+			}))
+		})
+
+		it("reports a dependency without a locked version", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte("gem 'thin'\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile.lock"), []byte("DEPENDENCIES\n  thin\n"), 0600)).To(Succeed())
+
+			drifts, err := checkGemfileDrift()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drifts).To(Equal([]string{
+				"Gemfile.lock:2 lists thin as a dependency but does not lock a version of it",
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when the Gemfile cannot be read", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte("gem 'thin'\n"), 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := checkGemfileDrift()
+					Expect(err).To(MatchError(ContainSubstring("failed to parse Gemfile:")))
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+
+			context("when the Gemfile has an invalid requirement", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte("gem 'thin', '~> 1.x.1'\n"), 0600)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile.lock"), []byte("GEM\n  specs:\n    thin (1.8.2)\n\nDEPENDENCIES\n  thin (~> 1.x.1)\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := checkGemfileDrift()
+					Expect(err).To(MatchError(`failed to check Gemfile:1: invalid gem requirement "~> 1.x.1"`))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,110 @@
+package thin
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// GemfileLock holds the parts of a Gemfile.lock that the buildpack inspects.
+type GemfileLock struct {
+	// Gems maps the name of every locked gem to its locked version.
+	Gems map[string]string
+
+	// GemLines maps the name of every locked gem to the line it is locked at.
+	GemLines map[string]int
+
+	// Dependencies maps the name of every gem listed in the DEPENDENCIES
+	// section to the way it is listed there.
+	Dependencies map[string]GemfileLockDependency
+
+	// RubyVersion is the version listed in the RUBY VERSION section, e.g.
+	// "3.1.3p185".
+	RubyVersion string
+}
+
+// GemfileLockDependency is a gem listed in the DEPENDENCIES section of a
+// Gemfile.lock.
+type GemfileLockDependency struct {
+	// Requirements are the version requirements of the gem as they are
+	// listed, e.g. ">= 1.8.1" and "~> 1.8".
+	Requirements []string
+
+	// Line is the line the gem is listed at.
+	Line int
+}
+
+type GemfileLockParser struct{}
+
+func NewGemfileLockParser() GemfileLockParser {
+	return GemfileLockParser{}
+}
+
+func (p GemfileLockParser) Parse(path string) (GemfileLock, error) {
+	lockfile := GemfileLock{
+		Gems:         map[string]string{},
+		GemLines:     map[string]int{},
+		Dependencies: map[string]GemfileLockDependency{},
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return lockfile, nil
+		}
+
+		return GemfileLock{}, fmt.Errorf("failed to parse Gemfile.lock: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			_ = err
+		}
+	}()
+
+	sectionRe := regexp.MustCompile(`^[A-Z][A-Z ]*$`)
+	specRe := regexp.MustCompile(`^    ([^\s(]+) \(([^)]+)\)$`)
+	dependencyRe := regexp.MustCompile(`^  ([^\s(!]+)!?(?: \(([^)]+)\))?$`)
+	rubyVersionRe := regexp.MustCompile(`^\s+ruby (\S+)`)
+
+	var section string
+	scanner := bufio.NewScanner(file)
+
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+
+		if sectionRe.MatchString(line) {
+			section = line
+			continue
+		}
+
+		switch section {
+		case "GEM", "GIT", "PATH":
+			if matches := specRe.FindStringSubmatch(line); matches != nil {
+				lockfile.Gems[matches[1]] = matches[2]
+				lockfile.GemLines[matches[1]] = number
+			}
+		case "DEPENDENCIES":
+			if matches := dependencyRe.FindStringSubmatch(line); matches != nil {
+				dependency := GemfileLockDependency{Line: number}
+				if matches[2] != "" {
+					for _, requirement := range strings.Split(matches[2], ",") {
+						dependency.Requirements = append(dependency.Requirements, strings.TrimSpace(requirement))
+					}
+				}
+				lockfile.Dependencies[matches[1]] = dependency
+			}
+		case "RUBY VERSION":
+			if matches := rubyVersionRe.FindStringSubmatch(line); matches != nil {
+				lockfile.RubyVersion = matches[1]
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return GemfileLock{}, fmt.Errorf("failed to parse Gemfile.lock: %w", err)
+	}
+
+	return lockfile, nil
+}
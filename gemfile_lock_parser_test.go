@@ -0,0 +1,131 @@
+package thin_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testGemfileLockParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser thin.GemfileLockParser
+	)
+
+	it.Before(func() {
+		file, err := os.CreateTemp("", "Gemfile.lock")
+		Expect(err).NotTo(HaveOccurred())
+		defer func() {
+			Expect(file.Close()).To(Succeed())
+		}()
+
+		path = file.Name()
+		parser = thin.NewGemfileLockParser()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(path)).To(Succeed())
+	})
+
+	context("Parse", func() {
+		it("parses the locked gem versions and the dependencies", func() {
+			Expect(os.WriteFile(path, []byte(`GIT
+  remote: https://github.com/rails/rails.git
+  revision: 0123456789abcdef
+  specs:
+    railties (7.1.3)
+      rack (>= 2.2.4)
+
+GEM
+  remote: https://rubygems.org/
+  specs:
+    daemons (1.4.1)
+    eventmachine (1.2.7)
+    nokogiri (1.16.0-x86_64-linux)
+    rack (2.2.6.2)
+    thin (1.8.1)
+      daemons (~> 1.0, >= 1.0.9)
+      eventmachine (~> 1.0, >= 1.0.4)
+      rack (>= 1, < 3)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  rack (>= 2.2.4, < 3)
+  railties!
+  thin
+
+RUBY VERSION
+   ruby 3.1.3p185
+
+BUNDLED WITH
+   2.3.26
+`), 0600)).To(Succeed())
+
+			lockfile, err := parser.Parse(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lockfile).To(Equal(thin.GemfileLock{
+				Gems: map[string]string{
+					"daemons":      "1.4.1",
+					"eventmachine": "1.2.7",
+					"nokogiri":     "1.16.0-x86_64-linux",
+					"rack":         "2.2.6.2",
+					"railties":     "7.1.3",
+					"thin":         "1.8.1",
+				},
+				GemLines: map[string]int{
+					"daemons":      11,
+					"eventmachine": 12,
+					"nokogiri":     13,
+					"rack":         14,
+					"railties":     5,
+					"thin":         15,
+				},
+				Dependencies: map[string]thin.GemfileLockDependency{
+					"rack":     {Requirements: []string{">= 2.2.4", "< 3"}, Line: 24},
+					"railties": {Line: 25},
+					"thin":     {Line: 26},
+				},
+				RubyVersion: "3.1.3p185",
+			}))
+		})
+
+		context("when the Gemfile.lock file does not exist", func() {
+			it.Before(func() {
+				Expect(os.Remove(path)).To(Succeed())
+			})
+
+			it("returns an empty lockfile", func() {
+				lockfile, err := parser.Parse(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lockfile).To(Equal(thin.GemfileLock{
+					Gems:         map[string]string{},
+					GemLines:     map[string]int{},
+					Dependencies: map[string]thin.GemfileLockDependency{},
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the Gemfile.lock cannot be opened", func() {
+				it.Before(func() {
+					Expect(os.Chmod(path, 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.Parse(path)
+					Expect(err).To(HaveOccurred())
+					Expect(err).To(MatchError(ContainSubstring("failed to parse Gemfile.lock:")))
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+		})
+	})
+}
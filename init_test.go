@@ -9,8 +9,20 @@ import (
 
 func TestUnitThin(t *testing.T) {
 	suite := spec.New("thin", spec.Report(report.Terminal{}), spec.Sequential())
+	suite("Advisory", testAdvisory)
 	suite("Build", testBuild)
+	suite("Compatibility", testCompatibility)
 	suite("Detect", testDetect)
+	suite("EventMachine", testEventMachine)
+	suite("GemPaths", testGemPaths)
+	suite("GemSBOM", testGemSBOM)
+	suite("GemVersion", testGemVersion)
+	suite("GemfileDrift", testGemfileDrift)
+	suite("GemfileLockParser", testGemfileLockParser)
 	suite("GemfileParser", testGemfileParser)
+	suite("Procfile", testProcfile)
+	suite("RackupWrapper", testRackupWrapper)
+	suite("RubyVersion", testRubyVersion)
+	suite("ThinConfig", testThinConfig)
 	suite.Run(t)
 }
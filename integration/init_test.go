@@ -94,5 +94,7 @@ func TestIntegration(t *testing.T) {
 	suite("SimpleApp", testSimpleApp)
 	suite("RackApp", testRackApp)
 	suite("ThinConfigFile", testThinConfigFile)
+	suite("ReadOnlyRoot", testReadOnlyRoot)
+	suite("EventMachine", testEventMachine)
 	suite.Run(t)
 }
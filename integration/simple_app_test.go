@@ -79,12 +79,52 @@ func testSimpleApp(t *testing.T, context spec.G, it spec.S) {
 
 				Expect(logs).To(ContainLines(
 					MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, settings.Buildpack.Name)),
+					"  Starting thin with the bundle-exec launcher",
+					"",
 					"  Assigning launch processes:",
 					`    web (default): bash -c bundle exec thin -R /workspace/config.ru -p "${PORT:-3000}" start`,
 				))
 			})
 		})
 
+		context("the bundler-setup launcher is selected", func() {
+			it("creates a working OCI image that starts the thin of the bundle", func() {
+				var err error
+				source, err = occam.Source(filepath.Join("testdata", "simple_app"))
+				Expect(err).NotTo(HaveOccurred())
+
+				var logs fmt.Stringer
+				image, logs, err = pack.WithNoColor().Build.
+					WithBuildpacks(
+						settings.Buildpacks.MRI.Online,
+						settings.Buildpacks.Bundler.Online,
+						settings.Buildpacks.BundleInstall.Online,
+						settings.Buildpacks.Thin.Online,
+					).
+					WithEnv(map[string]string{"BP_THIN_LAUNCHER": "bundler-setup"}).
+					WithPullPolicy("never").
+					Execute(name, source)
+				Expect(err).NotTo(HaveOccurred(), logs.String())
+
+				container, err = docker.Container.Run.
+					WithPublish("3000").
+					WithPublishAll().
+					Execute(image.ID)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(container).Should(BeAvailable())
+				Eventually(container).Should(Serve(ContainSubstring("Hello world!")).OnPort(3000))
+
+				Expect(logs).To(ContainLines(
+					MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, settings.Buildpack.Name)),
+					"  Starting thin with the bundler-setup launcher",
+					"",
+					"  Assigning launch processes:",
+					`    web (default): bash -c ruby -rbundler/setup -e 'load Gem.bin_path("thin", "thin")' -- -R /workspace/config.ru -p "${PORT:-3000}" start`,
+				))
+			})
+		})
+
 		context("no container port is specified", func() {
 			it("creates a working OCI image with a thin start command", func() {
 				var err error
@@ -114,6 +154,8 @@ func testSimpleApp(t *testing.T, context spec.G, it spec.S) {
 
 				Expect(logs).To(ContainLines(
 					MatchRegexp(fmt.Sprintf(`%s \d+\.\d+\.\d+`, settings.Buildpack.Name)),
+					"  Starting thin with the bundle-exec launcher",
+					"",
 					"  Assigning launch processes:",
 					`    web (default): bash -c bundle exec thin -R /workspace/config.ru -p "${PORT:-3000}" start`,
 				))
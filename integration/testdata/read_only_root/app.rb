@@ -0,0 +1,6 @@
+require 'sinatra'
+configure { set :server, :thin }
+
+get '/' do
+  'Hello world!'
+end
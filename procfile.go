@@ -0,0 +1,295 @@
+package thin
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ParseProcfile returns the commands of the Procfile at the given path, keyed
+// by their process type. It returns an empty map if there is no Procfile.
+func ParseProcfile(path string) (map[string]string, error) {
+	processes := map[string]string{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return processes, nil
+		}
+
+		return nil, fmt.Errorf("failed to parse Procfile: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			_ = err
+		}
+	}()
+
+	processRe := regexp.MustCompile(`^([A-Za-z0-9_.-]+):\s*(.+)$`)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if matches := processRe.FindStringSubmatch(strings.TrimSpace(scanner.Text())); matches != nil {
+			processes[matches[1]] = strings.TrimSpace(matches[2])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse Procfile: %w", err)
+	}
+
+	return processes, nil
+}
+
+// thinValueOptions and thinFlagOptions are the options of the thin start
+// command that do and do not take a value. thinContainerOptions are the
+// options that daemonize thin or run a cluster of thin servers, which cannot
+// be used in a container.
+var (
+	thinValueOptions = []string{
+		"-a", "--address", "-p", "--port", "-S", "--socket", "-y", "--swiftiply",
+		"-A", "--adapter", "-R", "--rackup", "-c", "--chdir", "--stats",
+		"-e", "--environment", "--prefix", "-l", "--log", "-P", "--pid",
+		"-u", "--user", "-g", "--group", "--tag", "-C", "--config",
+		"-b", "--backend", "-t", "--timeout", "--max-conns",
+		"--max-persistent-conns", "--threadpool-size", "-r", "--require",
+		"--ssl-key-file", "--ssl-cert-file", "--ssl-version", "--ssl-cipher-list",
+	}
+
+	thinFlagOptions = []string{
+		"--ssl", "--ssl-disable-verify", "--threaded", "--no-epoll",
+		"-f", "--force", "-D", "--debug", "-V", "--trace", "-q", "--quiet",
+	}
+
+	thinContainerOptions = []string{
+		"-d", "--daemonize", "-s", "--servers", "-o", "--only",
+		"-O", "--onebyone", "-w", "--wait",
+	}
+)
+
+// ParseThinCommand reports whether the given Procfile command starts thin and,
+// if it does, returns the options it passes to thin, normalised for the
+// buildpack: the launcher and the start command are removed, and the port is
+// read from PORT. Options are returned as literal values, except for variable
+// references such as ${PORT:-3000}, which ShellJoin leaves to the shell. It
+// returns an error if a command that starts thin uses shell syntax beyond
+// quoting and variable references, or passes an option that thin does not
+// know or that cannot be used in a container.
+func ParseThinCommand(command string) ([]string, bool, error) {
+	fields, err := splitShellWords(command)
+	if err != nil {
+		if startsThin(strings.Fields(command)) {
+			return nil, true, err
+		}
+
+		return nil, false, nil
+	}
+
+	if !startsThin(fields) {
+		return nil, false, nil
+	}
+
+	if fields[0] == "bundle" {
+		fields = fields[3:]
+	} else {
+		fields = fields[1:]
+	}
+
+	var (
+		options []string
+		hasPort bool
+	)
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		if !strings.HasPrefix(field, "-") {
+			if field != "start" {
+				return nil, true, fmt.Errorf("unsupported thin command %q", field)
+			}
+
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(field, "=")
+		takesValue := slices.Contains(thinValueOptions, name)
+		if !takesValue && !slices.Contains(thinFlagOptions, name) {
+			if slices.Contains(thinContainerOptions, name) {
+				return nil, true, fmt.Errorf("thin option %s cannot be used in a container", name)
+			}
+
+			return nil, true, fmt.Errorf("unknown thin option %s", name)
+		}
+
+		if takesValue && !hasValue {
+			if i+1 == len(fields) {
+				return nil, true, fmt.Errorf("thin option %s requires a value", name)
+			}
+
+			i++
+			value = fields[i]
+		}
+
+		switch name {
+		case "-p", "--port":
+			hasPort = true
+			if value == "$PORT" || value == "${PORT}" {
+				value = "${PORT:-3000}"
+			}
+		case "-S", "--socket":
+			hasPort = true
+		}
+
+		if takesValue {
+			options = append(options, name, value)
+		} else {
+			options = append(options, name)
+		}
+	}
+
+	if !hasPort {
+		options = append(options, "-p", "${PORT:-3000}")
+	}
+
+	return options, true, nil
+}
+
+// thinOption returns the value of the last of the given options in the thin
+// options, and whether any of them is present.
+func thinOption(options []string, names ...string) (string, bool) {
+	var (
+		value string
+		found bool
+	)
+
+	for i := 0; i+1 < len(options); i++ {
+		if slices.Contains(names, options[i]) {
+			value = options[i+1]
+			found = true
+		}
+	}
+
+	return value, found
+}
+
+// startsThin reports whether the given command words start thin, directly or
+// through bundle exec.
+func startsThin(fields []string) bool {
+	switch {
+	case len(fields) >= 3 && fields[0] == "bundle" && fields[1] == "exec" && fields[2] == "thin":
+		return true
+	case len(fields) >= 1 && (fields[0] == "thin" || fields[0] == "bin/thin" || fields[0] == "./bin/thin"):
+		return true
+	default:
+		return false
+	}
+}
+
+// splitShellWords splits a command into words the way the shell does for a
+// simple command: words are separated by whitespace, and single quotes,
+// double quotes and backslashes quote the characters they enclose. A $ that
+// the shell would expand is kept in the word, while a $ that the shell would
+// not expand is rejected, so that ShellJoin can quote the words again. It
+// returns an error for any other shell syntax, such as ;, && or command
+// substitution.
+func splitShellWords(command string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+	)
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in %q", command)
+			}
+
+			value := command[i+1 : i+1+end]
+			if strings.Contains(value, "$") {
+				return nil, fmt.Errorf("literal $ in %q is not supported", command)
+			}
+
+			word.WriteString(value)
+			inWord = true
+			i += end + 1
+
+		case c == '"':
+			for i++; i < len(command) && command[i] != '"'; i++ {
+				switch {
+				case command[i] == '`' || strings.HasPrefix(command[i:], "$("):
+					return nil, fmt.Errorf("command substitution in %q is not supported", command)
+				case command[i] == '\\' && strings.HasPrefix(command[i:], `\$`):
+					return nil, fmt.Errorf("literal $ in %q is not supported", command)
+				case command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\"\\`", command[i+1]) >= 0:
+					i++
+					word.WriteByte(command[i])
+				default:
+					word.WriteByte(command[i])
+				}
+			}
+
+			if i == len(command) {
+				return nil, fmt.Errorf("unterminated quote in %q", command)
+			}
+			inWord = true
+
+		case c == '\\':
+			if i+1 == len(command) || command[i+1] == '$' {
+				return nil, fmt.Errorf("unsupported backslash in %q", command)
+			}
+
+			i++
+			word.WriteByte(command[i])
+			inWord = true
+
+		case strings.IndexByte(";&|<>()`", c) >= 0 || strings.HasPrefix(command[i:], "$("):
+			return nil, fmt.Errorf("shell syntax %q in %q is not supported", string(c), command)
+
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// ShellJoin joins words into a shell command line, quoting them so that the
+// shell passes them on unchanged, apart from expanding the variable
+// references in them.
+func ShellJoin(words []string) string {
+	plainRe := regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		switch {
+		case plainRe.MatchString(word):
+			quoted = append(quoted, word)
+		case strings.Contains(word, "$"):
+			quoted = append(quoted, `"`+strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(word)+`"`)
+		default:
+			quoted = append(quoted, `'`+strings.ReplaceAll(word, `'`, `'\''`)+`'`)
+		}
+	}
+
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,160 @@
+package thin_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testProcfile(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path string
+	)
+
+	it.Before(func() {
+		file, err := os.CreateTemp("", "Procfile")
+		Expect(err).NotTo(HaveOccurred())
+		defer func() {
+			Expect(file.Close()).To(Succeed())
+		}()
+
+		path = file.Name()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(path)).To(Succeed())
+	})
+
+	context("ParseProcfile", func() {
+		it("parses the process types and their commands", func() {
+			Expect(os.WriteFile(path, []byte(`# processes
+web: bundle exec thin start -p $PORT
+worker:   bundle exec rake jobs:work
+
+`), 0600)).To(Succeed())
+
+			processes, err := thin.ParseProcfile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(processes).To(Equal(map[string]string{
+				"web":    "bundle exec thin start -p $PORT",
+				"worker": "bundle exec rake jobs:work",
+			}))
+		})
+
+		context("when the Procfile does not exist", func() {
+			it.Before(func() {
+				Expect(os.Remove(path)).To(Succeed())
+			})
+
+			it("returns no processes", func() {
+				processes, err := thin.ParseProcfile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(processes).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the Procfile cannot be opened", func() {
+				it.Before(func() {
+					Expect(os.Chmod(path, 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := thin.ParseProcfile(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse Procfile:")))
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+		})
+	})
+
+	context("ParseThinCommand", func() {
+		it("returns the normalised thin options", func() {
+			for command, expected := range map[string][]string{
+				"bundle exec thin start -R config.ru -p $PORT":                 {"-R", "config.ru", "-p", "${PORT:-3000}"},
+				"bin/thin start --port=${PORT} -e production":                  {"--port", "${PORT:-3000}", "-e", "production"},
+				"thin -C config/thin.yml start":                                {"-C", "config/thin.yml", "-p", "${PORT:-3000}"},
+				"thin start --socket /tmp/thin.sock --trace":                   {"--socket", "/tmp/thin.sock", "--trace"},
+				`bundle exec thin start -p "$PORT"`:                            {"-p", "${PORT:-3000}"},
+				`thin start --port="${PORT}" -e "$RACK_ENV"`:                   {"--port", "${PORT:-3000}", "-e", "$RACK_ENV"},
+				`thin start -R 'my app.ru' --tag my\ app -p "${PORT}"`:         {"-R", "my app.ru", "--tag", "my app", "-p", "${PORT:-3000}"},
+				"bundle exec thin start -b Thin::Backends::TcpServer -p $PORT": {"-b", "Thin::Backends::TcpServer", "-p", "${PORT:-3000}"},
+			} {
+				options, runsThin, err := thin.ParseThinCommand(command)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(runsThin).To(BeTrue(), command)
+				Expect(options).To(Equal(expected), command)
+			}
+		})
+
+		it("reports commands that do not run thin", func() {
+			for _, command := range []string{
+				"bundle exec puma",
+				`bundle exec puma -p "$PORT"`,
+				"bin/setup && bundle exec puma",
+				"RACK_ENV=staging bundle exec thin start",
+			} {
+				_, runsThin, err := thin.ParseThinCommand(command)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(runsThin).To(BeFalse(), command)
+			}
+		})
+
+		context("failure cases", func() {
+			it("returns an error for unknown options", func() {
+				_, _, err := thin.ParseThinCommand("thin start --workers 4")
+				Expect(err).To(MatchError("unknown thin option --workers"))
+			})
+
+			it("returns an error for options that cannot be used in a container", func() {
+				for command, expected := range map[string]string{
+					"thin start -d":          "thin option -d cannot be used in a container",
+					"thin start --servers=3": "thin option --servers cannot be used in a container",
+					"thin start -o 1":        "thin option -o cannot be used in a container",
+					"thin start --onebyone":  "thin option --onebyone cannot be used in a container",
+					"thin start -w 30":       "thin option -w cannot be used in a container",
+				} {
+					_, _, err := thin.ParseThinCommand(command)
+					Expect(err).To(MatchError(expected), command)
+				}
+			})
+
+			it("returns an error for options that are missing their value", func() {
+				_, _, err := thin.ParseThinCommand("thin start -p")
+				Expect(err).To(MatchError("thin option -p requires a value"))
+			})
+
+			it("returns an error for thin commands that use other shell syntax", func() {
+				for command, expected := range map[string]string{
+					"bundle exec thin start -p $PORT && echo done": `shell syntax "&" in "bundle exec thin start -p $PORT && echo done" is not supported`,
+					`thin start -p "$(cat port)"`:                  `command substitution in "thin start -p \"$(cat port)\"" is not supported`,
+					`thin start -e 'production`:                    `unterminated quote in "thin start -e 'production"`,
+					`thin start --tag '$HOME'`:                     `literal $ in "thin start --tag '$HOME'" is not supported`,
+				} {
+					_, runsThin, err := thin.ParseThinCommand(command)
+					Expect(runsThin).To(BeTrue(), command)
+					Expect(err).To(MatchError(expected), command)
+				}
+			})
+
+			it("returns an error for thin commands other than start", func() {
+				_, _, err := thin.ParseThinCommand("thin restart")
+				Expect(err).To(MatchError(`unsupported thin command "restart"`))
+			})
+		})
+	})
+
+	context("ShellJoin", func() {
+		it("quotes the words for the shell, leaving variable references to it", func() {
+			Expect(thin.ShellJoin([]string{"-R", "my app.ru", "--tag", "it's", "-p", "${PORT:-3000}", "-e", `$RACK_ENV"x`})).To(Equal(
+				`-R 'my app.ru' --tag 'it'\''s' -p "${PORT:-3000}" -e "$RACK_ENV\"x"`,
+			))
+		})
+	})
+}
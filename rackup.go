@@ -0,0 +1,107 @@
+package thin
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed rackup.ru.tmpl
+var rackupSource string
+
+// rackupTemplate generates the Ruby code of the wrapper rackup file from a
+// RackupWrapper.
+var rackupTemplate = template.Must(template.New("rackup.ru").Funcs(template.FuncMap{
+	"ruby": rubyString,
+	"reverse": func(values []string) []string {
+		values = slices.Clone(values)
+		slices.Reverse(values)
+		return values
+	},
+}).Parse(rackupSource))
+
+// RackupWrapper is a rackup file that the buildpack generates and passes to
+// thin in place of the rackup file of the app, to serve endpoints and
+// middleware in front of the app.
+type RackupWrapper struct {
+	// Rackup is the path of the rackup file of the app.
+	Rackup string
+
+	// StatsPath is the URL path that serves the statistics of
+	// Thin::Stats::Adapter, or empty.
+	StatsPath string
+
+	// StatsAllowUnauthenticated serves the statistics without authentication
+	// when there is no thin-stats service binding at launch. Otherwise the
+	// wrapper does not load without one, and requests for the statistics must
+	// authenticate with its username and password using basic auth.
+	StatsAllowUnauthenticated bool
+
+	// HealthPath is the URL path that answers 200 once the app has loaded,
+	// without passing the request to the app, or empty.
+	HealthPath string
+
+	// Gzip compresses responses with Rack::Deflater.
+	Gzip bool
+
+	// StaticDirs are the directories whose files Rack::Static serves ahead of
+	// the app, at the URL path of their path within the directory.
+	StaticDirs []string
+
+	// StaticMaxAge is the max-age, in seconds, of the Cache-Control header of
+	// the files served from StaticDirs.
+	StaticMaxAge int
+}
+
+// Enabled reports whether the wrapper serves anything in front of the app.
+func (w RackupWrapper) Enabled() bool {
+	return w.StatsPath != "" || w.HealthPath != "" || w.Gzip || len(w.StaticDirs) > 0
+}
+
+// Settings names the environment variables that enable the wrapper.
+func (w RackupWrapper) Settings() []string {
+	var settings []string
+	if w.StatsPath != "" {
+		settings = append(settings, "BP_THIN_STATS_PATH")
+	}
+
+	if w.HealthPath != "" {
+		settings = append(settings, "BP_THIN_HEALTH_PATH")
+	}
+
+	if w.Gzip {
+		settings = append(settings, "BP_THIN_GZIP")
+	}
+
+	if len(w.StaticDirs) > 0 {
+		settings = append(settings, "BP_THIN_STATIC_DIRS")
+	}
+
+	return settings
+}
+
+// Write generates the wrapper rackup file at the given path.
+func (w RackupWrapper) Write(path string) error {
+	var content strings.Builder
+	err := rackupTemplate.Execute(&content, w)
+	if err != nil {
+		return fmt.Errorf("failed to generate rackup wrapper: %w", err)
+	}
+
+	err = os.WriteFile(path, []byte(content.String()), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write rackup wrapper: %w", err)
+	}
+
+	return nil
+}
+
+// rubyString quotes s as a Ruby string literal in which nothing is
+// interpolated.
+func rubyString(s string) string {
+	return strings.ReplaceAll(strconv.Quote(s), "#", `\#`)
+}
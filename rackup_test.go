@@ -0,0 +1,164 @@
+package thin_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRackupWrapper(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		dir string
+	)
+
+	it.Before(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "rackup")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	context("Write", func() {
+		it("wraps the rackup file of the app", func() {
+			wrapper := thin.RackupWrapper{Rackup: "/workspace/config.ru"}
+			Expect(wrapper.Write(filepath.Join(dir, "config.ru"))).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(dir, "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`app = Rack::Builder.parse_file("/workspace/config.ru")`))
+			Expect(string(content)).To(HaveSuffix("\nrun app\n"))
+			Expect(string(content)).NotTo(ContainSubstring("Thin::Stats::Adapter"))
+		})
+
+		it("serves thin stats behind basic auth with the credentials of the thin-stats binding at launch", func() {
+			wrapper := thin.RackupWrapper{
+				Rackup:    "/workspace/config.ru",
+				StatsPath: "/stats",
+			}
+			Expect(wrapper.Enabled()).To(BeTrue())
+			Expect(wrapper.Settings()).To(Equal([]string{"BP_THIN_STATS_PATH"}))
+			Expect(wrapper.Write(filepath.Join(dir, "config.ru"))).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(dir, "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`app = Thin::Stats::Adapter.new(app, "/stats")`))
+			Expect(string(content)).To(ContainSubstring(`binding_root = ENV["SERVICE_BINDING_ROOT"].to_s`))
+			Expect(string(content)).To(ContainSubstring(`binding_root = ENV["CNB_BINDINGS"].to_s if binding_root.empty?`))
+			Expect(string(content)).To(ContainSubstring(`File.read(type).strip == "thin-stats"`))
+			Expect(string(content)).To(ContainSubstring(`  stats_auth = Rack::Auth::Basic.new(stats, "thin stats") do |username, password|`))
+			Expect(string(content)).To(ContainSubstring(`    Rack::Utils.secure_compare(username, stats_credentials[0]) & Rack::Utils.secure_compare(password, stats_credentials[1])`))
+			Expect(string(content)).To(ContainSubstring(`  app = lambda { |env| env["PATH_INFO"].start_with?("/stats") ? stats_auth.call(env) : stats.call(env) }`))
+			Expect(string(content)).To(ContainSubstring(`  abort "thin stats require a thin-stats service binding with a username and a password`))
+			Expect(string(content)).NotTo(ContainSubstring("Digest"))
+		})
+
+		it("serves thin stats without authentication when unauthenticated stats are allowed and there is no binding", func() {
+			wrapper := thin.RackupWrapper{
+				Rackup:                    "/workspace/config.ru",
+				StatsPath:                 "/stats",
+				StatsAllowUnauthenticated: true,
+			}
+			Expect(wrapper.Write(filepath.Join(dir, "config.ru"))).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(dir, "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`app = Thin::Stats::Adapter.new(app, "/stats")`))
+			Expect(string(content)).To(ContainSubstring(`  warn "WARNING: thin stats are served without authentication, since there is no thin-stats service binding"`))
+			Expect(string(content)).NotTo(ContainSubstring("abort \"thin stats require"))
+		})
+
+		it("answers a health check ahead of the app", func() {
+			wrapper := thin.RackupWrapper{
+				Rackup:     "/workspace/config.ru",
+				HealthPath: "/healthz",
+			}
+			Expect(wrapper.Enabled()).To(BeTrue())
+			Expect(wrapper.Settings()).To(Equal([]string{"BP_THIN_HEALTH_PATH"}))
+			Expect(wrapper.Write(filepath.Join(dir, "config.ru"))).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(dir, "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`health = app`))
+			Expect(string(content)).To(ContainSubstring(`app = lambda { |env| env["PATH_INFO"] == "/healthz" ? [200, { "content-type" => "text/plain" }, ["ok"]] : health.call(env) }`))
+			Expect(string(content)).To(HaveSuffix("\nrun app\n"))
+		})
+
+		it("compresses responses and serves static files ahead of the app", func() {
+			wrapper := thin.RackupWrapper{
+				Rackup:       "/workspace/config.ru",
+				Gzip:         true,
+				StaticDirs:   []string{"/workspace/public", "/workspace/assets"},
+				StaticMaxAge: 600,
+			}
+			Expect(wrapper.Enabled()).To(BeTrue())
+			Expect(wrapper.Settings()).To(Equal([]string{"BP_THIN_GZIP", "BP_THIN_STATIC_DIRS"}))
+			Expect(wrapper.Write(filepath.Join(dir, "config.ru"))).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(dir, "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(HaveSuffix(`
+serve_static = lambda do |inner, root|
+  static = Rack::Static.new(inner, root: root, urls: [""], cascade: true, header_rules: [[:all, { "cache-control" => "public, max-age=600" }]])
+  lambda { |env| %w[GET HEAD].include?(env["REQUEST_METHOD"]) ? static.call(env) : inner.call(env) }
+end
+app = serve_static.call(app, "/workspace/assets")
+app = serve_static.call(app, "/workspace/public")
+
+app = Rack::Deflater.new(app)
+
+run app
+`))
+			Expect(string(content)).NotTo(ContainSubstring("use Rack::"))
+		})
+
+		it("answers a health check ahead of the static files and compression", func() {
+			wrapper := thin.RackupWrapper{
+				Rackup:       "/workspace/config.ru",
+				HealthPath:   "/healthz",
+				Gzip:         true,
+				StaticDirs:   []string{"/workspace/public"},
+				StaticMaxAge: 3600,
+			}
+			Expect(wrapper.Write(filepath.Join(dir, "config.ru"))).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(dir, "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(HaveSuffix(`
+app = Rack::Deflater.new(app)
+
+health = app
+app = lambda { |env| env["PATH_INFO"] == "/healthz" ? [200, { "content-type" => "text/plain" }, ["ok"]] : health.call(env) }
+
+run app
+`))
+		})
+
+		it("does not interpolate paths into the generated Ruby", func() {
+			wrapper := thin.RackupWrapper{Rackup: `/workspace/#{exit}"/config.ru`}
+			Expect(wrapper.Write(filepath.Join(dir, "config.ru"))).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(dir, "config.ru"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`app = Rack::Builder.parse_file("/workspace/\#{exit}\"/config.ru")`))
+		})
+
+		context("failure cases", func() {
+			context("when the wrapper cannot be written", func() {
+				it("returns an error", func() {
+					err := thin.RackupWrapper{}.Write(filepath.Join(dir, "missing", "config.ru"))
+					Expect(err).To(MatchError(ContainSubstring("failed to write rackup wrapper:")))
+				})
+			})
+		})
+	})
+}
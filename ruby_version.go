@@ -0,0 +1,105 @@
+package thin
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// RubyVersion is the Ruby version an app declares, along with the file it was
+// declared in.
+type RubyVersion struct {
+	// Version is the declared version or version constraint, e.g. "3.3.0" or
+	// "~> 3.3".
+	Version string
+
+	// Source is the name of the file the version was declared in.
+	Source string
+}
+
+// ResolveRubyVersion determines the Ruby version of the app from the most
+// precise source available: the RUBY VERSION section of the Gemfile.lock, the
+// .ruby-version file, or the ruby directive of the Gemfile. It returns an
+// empty RubyVersion if none of them declare a version.
+func ResolveRubyVersion(workingDir string, lockfile GemfileLock) (RubyVersion, error) {
+	if lockfile.RubyVersion != "" {
+		return RubyVersion{Version: lockfile.RubyVersion, Source: "Gemfile.lock"}, nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(workingDir, ".ruby-version"))
+	if err != nil && !os.IsNotExist(err) {
+		return RubyVersion{}, fmt.Errorf("failed to read .ruby-version: %w", err)
+	}
+
+	if version := strings.TrimSpace(string(content)); version != "" {
+		return RubyVersion{Version: version, Source: ".ruby-version"}, nil
+	}
+
+	file, err := os.Open(filepath.Join(workingDir, "Gemfile"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return RubyVersion{}, nil
+		}
+
+		return RubyVersion{}, fmt.Errorf("failed to parse Gemfile: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			_ = err
+		}
+	}()
+
+	rubyRe := regexp.MustCompile(`^\s*ruby\s*\(?\s*["']([^"']+)["']`)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if matches := rubyRe.FindStringSubmatch(scanner.Text()); matches != nil {
+			return RubyVersion{Version: matches[1], Source: "Gemfile"}, nil
+		}
+	}
+
+	return RubyVersion{}, nil
+}
+
+// LowerBound returns the lowest Ruby version that the declared version allows,
+// ignoring any patch level suffix (e.g. "p185"). Prereleases such as
+// "3.4.0.preview1" or "3.3.0-rc1" are understood, and for a list of
+// constraints (e.g. ">= 3.1, < 3.4") the highest of their lower bounds is
+// returned. It returns nil if the declared version has no lower bound (e.g.
+// "< 4"), and an error if the declared version cannot be interpreted.
+func (v RubyVersion) LowerBound() (*semver.Version, error) {
+	var lowerBound *semver.Version
+	for _, constraint := range strings.Split(v.Version, ",") {
+		constraint = strings.TrimSpace(constraint)
+		constraint = strings.TrimPrefix(constraint, "ruby-")
+
+		if constraint == "" || strings.HasPrefix(constraint, "<") || strings.HasPrefix(constraint, "!=") {
+			continue
+		}
+
+		constraint = strings.TrimLeft(constraint, "~>= ")
+		constraint = patchLevelRe.ReplaceAllString(constraint, "")
+		constraint = prereleaseRe.ReplaceAllString(constraint, "$1-$2")
+
+		version, err := semver.NewVersion(constraint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Ruby version %q: %w", v.Version, err)
+		}
+
+		if lowerBound == nil || version.GreaterThan(lowerBound) {
+			lowerBound = version
+		}
+	}
+
+	return lowerBound, nil
+}
+
+var (
+	patchLevelRe = regexp.MustCompile(`p\d+$`)
+	prereleaseRe = regexp.MustCompile(`^(\d+(?:\.\d+)*)\.([a-z][0-9a-z]*)$`)
+)
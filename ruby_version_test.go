@@ -0,0 +1,125 @@
+package thin_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRubyVersion(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte(`source 'https://rubygems.org'
+
+ruby '~> 3.3'
+
+gem 'thin'
+`), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, ".ruby-version"), []byte("ruby-3.3.1\n"), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("ResolveRubyVersion", func() {
+		it("prefers the Gemfile.lock", func() {
+			version, err := thin.ResolveRubyVersion(workingDir, thin.GemfileLock{RubyVersion: "3.3.0p0"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(thin.RubyVersion{Version: "3.3.0p0", Source: "Gemfile.lock"}))
+		})
+
+		it("falls back to the .ruby-version file", func() {
+			version, err := thin.ResolveRubyVersion(workingDir, thin.GemfileLock{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal(thin.RubyVersion{Version: "ruby-3.3.1", Source: ".ruby-version"}))
+		})
+
+		context("when there is no .ruby-version file", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, ".ruby-version"))).To(Succeed())
+			})
+
+			it("falls back to the ruby directive of the Gemfile", func() {
+				version, err := thin.ResolveRubyVersion(workingDir, thin.GemfileLock{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(Equal(thin.RubyVersion{Version: "~> 3.3", Source: "Gemfile"}))
+			})
+
+			context("when the Gemfile has no ruby directive", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "Gemfile"), []byte("gem 'thin'\n"), 0600)).To(Succeed())
+				})
+
+				it("returns an empty version", func() {
+					version, err := thin.ResolveRubyVersion(workingDir, thin.GemfileLock{})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(version).To(Equal(thin.RubyVersion{}))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the .ruby-version file cannot be read", func() {
+				it.Before(func() {
+					Expect(os.Chmod(filepath.Join(workingDir, ".ruby-version"), 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := thin.ResolveRubyVersion(workingDir, thin.GemfileLock{})
+					Expect(err).To(MatchError(ContainSubstring("failed to read .ruby-version:")))
+				})
+			})
+		})
+	})
+
+	context("LowerBound", func() {
+		it("returns the lowest version allowed by the declared version", func() {
+			for version, expected := range map[string]string{
+				"3.1.3p185":        "3.1.3",
+				"ruby-3.3.1":       "3.3.1",
+				"~> 3.3":           "3.3.0",
+				">= 3.2.1":         "3.2.1",
+				"3.4.0.preview1":   "3.4.0-preview1",
+				"ruby-3.3.0-rc1":   "3.3.0-rc1",
+				">= 3.1, < 3.4":    "3.1.0",
+				"~> 3.2, >= 3.2.3": "3.2.3",
+			} {
+				lowerBound, err := thin.RubyVersion{Version: version}.LowerBound()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lowerBound.String()).To(Equal(expected), version)
+			}
+		})
+
+		it("returns nil when there is no lower bound", func() {
+			for _, version := range []string{"", "< 4", "!= 3.0.0, < 4"} {
+				lowerBound, err := thin.RubyVersion{Version: version}.LowerBound()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lowerBound).To(BeNil(), version)
+			}
+		})
+
+		context("failure cases", func() {
+			context("when the declared version cannot be interpreted", func() {
+				it("returns an error", func() {
+					_, err := thin.RubyVersion{Version: "head"}.LowerBound()
+					Expect(err).To(MatchError(ContainSubstring(`failed to parse Ruby version "head"`)))
+				})
+			})
+		})
+	})
+}
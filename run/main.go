@@ -5,15 +5,18 @@ import (
 
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/paketo-buildpacks/packit/v2/servicebindings"
 	"github.com/paketo-buildpacks/thin"
 )
 
 func main() {
 	parser := thin.NewGemfileParser()
+	lockfileParser := thin.NewGemfileLockParser()
+	bindingResolver := servicebindings.NewResolver()
 	logger := scribe.NewEmitter(os.Stdout).WithLevel(os.Getenv("BP_LOG_LEVEL"))
 
 	packit.Run(
 		thin.Detect(parser),
-		thin.Build(logger),
+		thin.Build(lockfileParser, bindingResolver, logger),
 	)
 }
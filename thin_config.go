@@ -0,0 +1,125 @@
+package thin
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ThinConfig holds the options of a thin config file keyed by option name,
+// e.g. "port", "socket" or "log".
+type ThinConfig map[string]interface{}
+
+// ParseThinConfig reads the thin config file at the given path. An empty file
+// results in an empty config.
+func ParseThinConfig(path string) (ThinConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read thin config file: %w", err)
+	}
+
+	config, err := DecodeThinConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse thin config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// DecodeThinConfig decodes thin config content given as YAML or JSON. Empty
+// content results in an empty config.
+func DecodeThinConfig(content []byte) (ThinConfig, error) {
+	config := map[string]interface{}{}
+	err := yaml.Unmarshal(content, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return ThinConfig(config), nil
+}
+
+// MergeThinConfigs parses the thin config files at the given paths and
+// deep-merges them in order, so that options from later files win over the
+// same options from earlier files. Alongside the merged config it returns the
+// provenance of every option: a map from the option name (nested options are
+// joined with ".") to the path of the file it was taken from.
+func MergeThinConfigs(paths ...string) (ThinConfig, map[string]string, error) {
+	merged := ThinConfig{}
+	provenance := map[string]string{}
+
+	for _, path := range paths {
+		config, err := ParseThinConfig(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		mergeThinConfig(merged, config, "", path, provenance)
+	}
+
+	return merged, provenance, nil
+}
+
+func mergeThinConfig(dst, src map[string]interface{}, prefix, source string, provenance map[string]string) {
+	for key, value := range src {
+		name := prefix + key
+
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeThinConfig(dstMap, srcMap, name+".", source, provenance)
+			continue
+		}
+
+		for existing := range provenance {
+			if existing == name || strings.HasPrefix(existing, name+".") {
+				delete(provenance, existing)
+			}
+		}
+
+		if srcIsMap {
+			copied := map[string]interface{}{}
+			mergeThinConfig(copied, srcMap, name+".", source, provenance)
+			dst[key] = copied
+			continue
+		}
+
+		dst[key] = value
+		provenance[name] = source
+	}
+}
+
+// Lookup returns the value of the given option. Nested options are addressed
+// by joining their names with ".".
+func (c ThinConfig) Lookup(name string) (interface{}, bool) {
+	var current interface{} = map[string]interface{}(c)
+	for _, key := range strings.Split(name, ".") {
+		options, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = options[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// Write writes the config to the given path as YAML.
+func (c ThinConfig) Write(path string) error {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode thin config: %w", err)
+	}
+
+	err = os.WriteFile(path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write thin config file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package thin_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paketo-buildpacks/thin"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testThinConfig(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		dir string
+	)
+
+	it.Before(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "thin-config")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	context("ParseThinConfig", func() {
+		it("parses the config file", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "thin.yml"), []byte(`---
+port: 8080
+ssl:
+  enabled: true
+`), 0600)).To(Succeed())
+
+			config, err := thin.ParseThinConfig(filepath.Join(dir, "thin.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(thin.ThinConfig{
+				"port": 8080,
+				"ssl": map[string]interface{}{
+					"enabled": true,
+				},
+			}))
+		})
+
+		context("when the config file is empty", func() {
+			it("returns an empty config", func() {
+				Expect(os.WriteFile(filepath.Join(dir, "thin.yml"), []byte{}, 0600)).To(Succeed())
+
+				config, err := thin.ParseThinConfig(filepath.Join(dir, "thin.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config).To(Equal(thin.ThinConfig{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the config file does not exist", func() {
+				it("returns an error", func() {
+					_, err := thin.ParseThinConfig(filepath.Join(dir, "thin.yml"))
+					Expect(err).To(MatchError(ContainSubstring("failed to read thin config file:")))
+				})
+			})
+
+			context("when the config file is not valid YAML", func() {
+				it("returns an error", func() {
+					Expect(os.WriteFile(filepath.Join(dir, "thin.yml"), []byte("port: [8080"), 0600)).To(Succeed())
+
+					_, err := thin.ParseThinConfig(filepath.Join(dir, "thin.yml"))
+					Expect(err).To(MatchError(ContainSubstring("failed to parse thin config file")))
+				})
+			})
+		})
+	})
+
+	context("DecodeThinConfig", func() {
+		it("decodes YAML content", func() {
+			config, err := thin.DecodeThinConfig([]byte("port: 8080\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(thin.ThinConfig{"port": 8080}))
+		})
+
+		it("decodes JSON content", func() {
+			config, err := thin.DecodeThinConfig([]byte(`{"port": 8080, "ssl": {"enabled": true}}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(thin.ThinConfig{
+				"port": 8080,
+				"ssl": map[string]interface{}{
+					"enabled": true,
+				},
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when the content is not a mapping", func() {
+				it("returns an error", func() {
+					_, err := thin.DecodeThinConfig([]byte("- port"))
+					Expect(err).To(HaveOccurred())
+				})
+			})
+		})
+	})
+
+	context("MergeThinConfigs", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(dir, "base.yml"), []byte(`---
+port: 3000
+timeout: 30
+ssl:
+  enabled: true
+  cert: /base/cert.pem
+tags:
+- base
+`), 0600)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(dir, "override.yml"), []byte(`---
+port: 8080
+ssl:
+  cert: /app/cert.pem
+tags:
+- app
+`), 0600)).To(Succeed())
+		})
+
+		it("deep-merges the config files with later files winning", func() {
+			merged, provenance, err := thin.MergeThinConfigs(filepath.Join(dir, "base.yml"), filepath.Join(dir, "override.yml"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(merged).To(Equal(thin.ThinConfig{
+				"port":    8080,
+				"timeout": 30,
+				"ssl": map[string]interface{}{
+					"enabled": true,
+					"cert":    "/app/cert.pem",
+				},
+				"tags": []interface{}{"app"},
+			}))
+
+			Expect(provenance).To(Equal(map[string]string{
+				"port":        filepath.Join(dir, "override.yml"),
+				"timeout":     filepath.Join(dir, "base.yml"),
+				"ssl.enabled": filepath.Join(dir, "base.yml"),
+				"ssl.cert":    filepath.Join(dir, "override.yml"),
+				"tags":        filepath.Join(dir, "override.yml"),
+			}))
+		})
+
+		context("when a later file replaces a nested option with a scalar", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(dir, "override.yml"), []byte("ssl: false\n"), 0600)).To(Succeed())
+			})
+
+			it("drops the provenance of the replaced nested options", func() {
+				merged, provenance, err := thin.MergeThinConfigs(filepath.Join(dir, "base.yml"), filepath.Join(dir, "override.yml"))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(merged["ssl"]).To(BeFalse())
+				Expect(provenance).To(HaveKeyWithValue("ssl", filepath.Join(dir, "override.yml")))
+				Expect(provenance).NotTo(HaveKey("ssl.enabled"))
+				Expect(provenance).NotTo(HaveKey("ssl.cert"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when a config file cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(dir, "override.yml"), []byte("port: [8080"), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, _, err := thin.MergeThinConfigs(filepath.Join(dir, "base.yml"), filepath.Join(dir, "override.yml"))
+					Expect(err).To(MatchError(ContainSubstring("failed to parse thin config file")))
+				})
+			})
+		})
+	})
+
+	context("Lookup", func() {
+		it("returns top-level and nested options", func() {
+			config := thin.ThinConfig{
+				"port": 8080,
+				"ssl": map[string]interface{}{
+					"cert": "/app/cert.pem",
+				},
+			}
+
+			value, ok := config.Lookup("port")
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal(8080))
+
+			value, ok = config.Lookup("ssl.cert")
+			Expect(ok).To(BeTrue())
+			Expect(value).To(Equal("/app/cert.pem"))
+
+			_, ok = config.Lookup("port.number")
+			Expect(ok).To(BeFalse())
+
+			_, ok = config.Lookup("socket")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	context("Write", func() {
+		it("writes the config as YAML", func() {
+			config := thin.ThinConfig{
+				"port": 8080,
+				"ssl": map[string]interface{}{
+					"cert": "/app/cert.pem",
+				},
+			}
+
+			Expect(config.Write(filepath.Join(dir, "thin.yml"))).To(Succeed())
+
+			content, err := os.ReadFile(filepath.Join(dir, "thin.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal(`port: 8080
+ssl:
+    cert: /app/cert.pem
+`))
+		})
+
+		context("failure cases", func() {
+			context("when the file cannot be written", func() {
+				it("returns an error", func() {
+					err := thin.ThinConfig{}.Write(filepath.Join(dir, "missing", "thin.yml"))
+					Expect(err).To(MatchError(ContainSubstring("failed to write thin config file:")))
+				})
+			})
+		})
+	})
+}